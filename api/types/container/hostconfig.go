@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/docker/go-units"
 	"github.com/moby/moby/api/types/blkiodev"
@@ -12,6 +13,11 @@ import (
 	"github.com/moby/moby/api/types/strslice"
 )
 
+// cdiDeviceDriver is the reserved DeviceRequest.Driver name that instructs
+// the daemon to resolve DeviceIDs as fully-qualified CDI device names
+// (vendor.com/class=name) instead of driver-specific identifiers.
+const cdiDeviceDriver = "cdi"
+
 // CgroupnsMode represents the cgroup namespace mode of the container
 type CgroupnsMode string
 
@@ -255,7 +261,8 @@ func (n PidMode) Container() (idOrName string) {
 }
 
 // DeviceRequest represents a request for devices from a device driver.
-// Used by GPU device drivers.
+// Used by GPU device drivers, and by the "cdi" pseudo-driver to request
+// Container Device Interface managed devices.
 type DeviceRequest struct {
 	Driver       string            // Name of device driver
 	Count        int               // Number of devices to request (-1 = All)
@@ -264,6 +271,91 @@ type DeviceRequest struct {
 	Options      map[string]string // Options to pass onto the device driver
 }
 
+// Validate checks that the DeviceRequest is well-formed. For the "cdi"
+// driver, DeviceIDs must be fully-qualified CDI device names
+// (vendor.com/class=name), and Count must be unset, since CDI devices are
+// always requested by name.
+func (d DeviceRequest) Validate() error {
+	if d.Driver != cdiDeviceDriver {
+		return nil
+	}
+	if d.Count != 0 {
+		return &errInvalidParameter{errors.New("invalid device request: DeviceIDs and Count cannot be used together for the 'cdi' driver")}
+	}
+	for _, id := range d.DeviceIDs {
+		if _, _, _, err := ParseCDIDeviceName(id); err != nil {
+			return &errInvalidParameter{fmt.Errorf("invalid device request: %w", err)}
+		}
+	}
+	return nil
+}
+
+// cdiVendorClass matches the vendor and class components of a CDI device
+// name, following the grammar in the CDI specification:
+// https://github.com/cdi-spec/spec/blob/main/spec.md#device-kind
+var cdiVendorClass = func() func(s string) bool {
+	isNameStart := func(r byte) bool {
+		return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9'
+	}
+	isNameChar := func(r byte) bool {
+		return isNameStart(r) || r == '_' || r == '-' || r == '.'
+	}
+	return func(s string) bool {
+		if s == "" || !isNameStart(s[0]) || !isNameStart(s[len(s)-1]) {
+			return false
+		}
+		for i := 0; i < len(s); i++ {
+			if !isNameChar(s[i]) {
+				return false
+			}
+		}
+		return true
+	}
+}()
+
+// isCDIQualifiedName reports whether name is a valid CDI device "name"
+// component: alphanumerics plus '_', '-', '.', ':'.
+func isCDIQualifiedName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '_', c == '-', c == '.', c == ':':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ParseCDIDeviceName parses a fully-qualified CDI device name of the form
+// "vendor.com/class=name" and returns its vendor, class, and name
+// components. It returns an error if name does not conform to the CDI
+// device-name grammar (exactly one "/" and one "=").
+func ParseCDIDeviceName(name string) (vendor, class, deviceName string, err error) {
+	vendorClass, deviceName, ok := strings.Cut(name, "=")
+	if !ok || deviceName == "" {
+		return "", "", "", fmt.Errorf("CDI device name %q must have exactly one '=' separating the qualifier from the device name", name)
+	}
+	vendor, class, ok = strings.Cut(vendorClass, "/")
+	if !ok {
+		return "", "", "", fmt.Errorf("CDI device name %q must have exactly one '/' separating vendor from class", name)
+	}
+	if !cdiVendorClass(vendor) {
+		return "", "", "", fmt.Errorf("CDI device name %q has an invalid vendor %q", name, vendor)
+	}
+	if !cdiVendorClass(class) {
+		return "", "", "", fmt.Errorf("CDI device name %q has an invalid class %q", name, class)
+	}
+	if !isCDIQualifiedName(deviceName) {
+		return "", "", "", fmt.Errorf("CDI device name %q has an invalid device name %q", name, deviceName)
+	}
+	return vendor, class, deviceName, nil
+}
+
 // DeviceMapping represents the device mapping between the host and the container.
 type DeviceMapping struct {
 	PathOnHost        string
@@ -275,6 +367,29 @@ type DeviceMapping struct {
 type RestartPolicy struct {
 	Name              RestartPolicyMode
 	MaximumRetryCount int
+
+	// InitialDelay is the delay before the first restart attempt.
+	// A zero value means "use the daemon default". Only valid for
+	// "on-failure", "always", and "unless-stopped".
+	InitialDelay time.Duration `json:",omitempty"`
+
+	// MaxDelay caps the delay between restart attempts after it has
+	// been grown by BackoffMultiplier. A zero value means "use the
+	// daemon default". Only valid for "on-failure", "always", and
+	// "unless-stopped".
+	MaxDelay time.Duration `json:",omitempty"`
+
+	// BackoffMultiplier is the factor applied to the delay after each
+	// restart attempt. A zero value means "use the daemon default",
+	// which is 2.0. Only valid for "on-failure", "always", and
+	// "unless-stopped".
+	BackoffMultiplier float64 `json:",omitempty"`
+
+	// UnhealthyGracePeriod is the amount of time a container is allowed
+	// to stay "unhealthy" before the "on-unhealthy" restart policy
+	// restarts it. A zero value means "use the daemon default". Only
+	// valid for the "on-unhealthy" restart policy.
+	UnhealthyGracePeriod time.Duration `json:",omitempty"`
 }
 
 type RestartPolicyMode string
@@ -284,6 +399,7 @@ const (
 	RestartPolicyAlways        RestartPolicyMode = "always"
 	RestartPolicyOnFailure     RestartPolicyMode = "on-failure"
 	RestartPolicyUnlessStopped RestartPolicyMode = "unless-stopped"
+	RestartPolicyOnUnhealthy   RestartPolicyMode = "on-unhealthy"
 )
 
 // IsNone indicates whether the container has the "no" restart policy.
@@ -311,13 +427,32 @@ func (rp *RestartPolicy) IsUnlessStopped() bool {
 	return rp.Name == RestartPolicyUnlessStopped
 }
 
+// IsOnUnhealthy indicates whether the container has the "on-unhealthy"
+// restart policy. This means the container will automatically restart
+// when its healthcheck transitions to "unhealthy", independent of its
+// exit code.
+func (rp *RestartPolicy) IsOnUnhealthy() bool {
+	return rp.Name == RestartPolicyOnUnhealthy
+}
+
 // IsSame compares two RestartPolicy to see if they are the same
 func (rp *RestartPolicy) IsSame(tp *RestartPolicy) bool {
-	return rp.Name == tp.Name && rp.MaximumRetryCount == tp.MaximumRetryCount
+	return rp.Name == tp.Name &&
+		rp.MaximumRetryCount == tp.MaximumRetryCount &&
+		rp.InitialDelay == tp.InitialDelay &&
+		rp.MaxDelay == tp.MaxDelay &&
+		rp.BackoffMultiplier == tp.BackoffMultiplier &&
+		rp.UnhealthyGracePeriod == tp.UnhealthyGracePeriod
 }
 
 // ValidateRestartPolicy validates the given RestartPolicy.
 func ValidateRestartPolicy(policy RestartPolicy) error {
+	if err := validateRestartBackoff(policy); err != nil {
+		return err
+	}
+	if err := validateUnhealthyGracePeriod(policy); err != nil {
+		return err
+	}
 	switch policy.Name {
 	case RestartPolicyAlways, RestartPolicyUnlessStopped, RestartPolicyDisabled:
 		if policy.MaximumRetryCount != 0 {
@@ -328,7 +463,7 @@ func ValidateRestartPolicy(policy RestartPolicy) error {
 			return &errInvalidParameter{errors.New(msg)}
 		}
 		return nil
-	case RestartPolicyOnFailure:
+	case RestartPolicyOnFailure, RestartPolicyOnUnhealthy:
 		if policy.MaximumRetryCount < 0 {
 			return &errInvalidParameter{errors.New("invalid restart policy: maximum retry count cannot be negative")}
 		}
@@ -339,8 +474,47 @@ func ValidateRestartPolicy(policy RestartPolicy) error {
 		// backward-compatibility.
 		return nil
 	default:
-		return &errInvalidParameter{fmt.Errorf("invalid restart policy: unknown policy '%s'; use one of '%s', '%s', '%s', or '%s'", policy.Name, RestartPolicyDisabled, RestartPolicyAlways, RestartPolicyOnFailure, RestartPolicyUnlessStopped)}
+		return &errInvalidParameter{fmt.Errorf("invalid restart policy: unknown policy '%s'; use one of '%s', '%s', '%s', '%s', or '%s'", policy.Name, RestartPolicyDisabled, RestartPolicyAlways, RestartPolicyOnFailure, RestartPolicyUnlessStopped, RestartPolicyOnUnhealthy)}
+	}
+}
+
+// validateRestartBackoff validates the backoff-related fields of policy.
+// InitialDelay, MaxDelay, and BackoffMultiplier are only meaningful for
+// restart policies that actually trigger restarts.
+func validateRestartBackoff(policy RestartPolicy) error {
+	if policy.InitialDelay == 0 && policy.MaxDelay == 0 && policy.BackoffMultiplier == 0 {
+		return nil
+	}
+	switch policy.Name {
+	case RestartPolicyOnFailure, RestartPolicyAlways, RestartPolicyUnlessStopped:
+	default:
+		return &errInvalidParameter{fmt.Errorf("invalid restart policy: backoff parameters can only be used with '%s', '%s', or '%s'", RestartPolicyOnFailure, RestartPolicyAlways, RestartPolicyUnlessStopped)}
+	}
+	if policy.InitialDelay < 0 {
+		return &errInvalidParameter{errors.New("invalid restart policy: initial delay cannot be negative")}
+	}
+	if policy.MaxDelay != 0 && policy.MaxDelay < policy.InitialDelay {
+		return &errInvalidParameter{errors.New("invalid restart policy: max delay cannot be less than the initial delay")}
+	}
+	if policy.BackoffMultiplier != 0 && policy.BackoffMultiplier < 1.0 {
+		return &errInvalidParameter{errors.New("invalid restart policy: backoff multiplier cannot be less than 1.0")}
+	}
+	return nil
+}
+
+// validateUnhealthyGracePeriod validates the UnhealthyGracePeriod field,
+// which is only meaningful for the "on-unhealthy" restart policy.
+func validateUnhealthyGracePeriod(policy RestartPolicy) error {
+	if policy.UnhealthyGracePeriod == 0 {
+		return nil
+	}
+	if policy.Name != RestartPolicyOnUnhealthy {
+		return &errInvalidParameter{fmt.Errorf("invalid restart policy: unhealthy grace period can only be used with '%s'", RestartPolicyOnUnhealthy)}
+	}
+	if policy.UnhealthyGracePeriod < 0 {
+		return &errInvalidParameter{errors.New("invalid restart policy: unhealthy grace period cannot be negative")}
 	}
+	return nil
 }
 
 // LogMode is a type to define the available modes for logging
@@ -0,0 +1,148 @@
+package container
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Namespaced OCI annotation keys used to project selected HostConfig fields
+// for interop with other OCI-native tools (podman, cri-o, containerd
+// shims) that persist equivalent state as annotations on the OCI spec.
+const (
+	annotationAutoRemove     = "io.moby.hostconfig.autoremove"
+	annotationCIDFile        = "io.moby.hostconfig.cidfile"
+	annotationRestartPolicy  = "io.moby.hostconfig.restart-policy"
+	annotationIpcMode        = "io.moby.hostconfig.ipc-mode"
+	annotationPidMode        = "io.moby.hostconfig.pid-mode"
+	annotationNetworkMode    = "io.moby.hostconfig.network-mode"
+	annotationPrivileged     = "io.moby.hostconfig.privileged"
+	annotationReadonlyRootfs = "io.moby.hostconfig.readonly-rootfs"
+	annotationInit           = "io.moby.hostconfig.init"
+	annotationRuntime        = "io.moby.hostconfig.runtime"
+)
+
+// ToAnnotations projects the subset of h that has a natural OCI-annotation
+// representation into a namespaced ("io.moby.hostconfig.*") annotation map.
+// Zero-valued fields are omitted, so the result only contains what was
+// explicitly set.
+func (h *HostConfig) ToAnnotations() map[string]string {
+	out := map[string]string{}
+	if h.AutoRemove {
+		out[annotationAutoRemove] = strconv.FormatBool(h.AutoRemove)
+	}
+	if h.ContainerIDFile != "" {
+		out[annotationCIDFile] = h.ContainerIDFile
+	}
+	if rp := restartPolicyToAnnotation(h.RestartPolicy); rp != "" {
+		out[annotationRestartPolicy] = rp
+	}
+	if h.IpcMode != "" {
+		out[annotationIpcMode] = string(h.IpcMode)
+	}
+	if h.PidMode != "" {
+		out[annotationPidMode] = string(h.PidMode)
+	}
+	if h.NetworkMode != "" {
+		out[annotationNetworkMode] = string(h.NetworkMode)
+	}
+	if h.Privileged {
+		out[annotationPrivileged] = strconv.FormatBool(h.Privileged)
+	}
+	if h.ReadonlyRootfs {
+		out[annotationReadonlyRootfs] = strconv.FormatBool(h.ReadonlyRootfs)
+	}
+	if h.Init != nil {
+		out[annotationInit] = strconv.FormatBool(*h.Init)
+	}
+	if h.Runtime != "" {
+		out[annotationRuntime] = h.Runtime
+	}
+	return out
+}
+
+// HostConfigFromAnnotations decodes a HostConfig from a namespaced
+// ("io.moby.hostconfig.*") annotation map, as produced by
+// (*HostConfig).ToAnnotations. Unknown keys are ignored, so the map may
+// carry other, unrelated OCI annotations alongside these.
+func HostConfigFromAnnotations(annotations map[string]string) (*HostConfig, error) {
+	h := &HostConfig{}
+	if v, ok := annotations[annotationAutoRemove]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &errInvalidParameter{err}
+		}
+		h.AutoRemove = b
+	}
+	if v, ok := annotations[annotationCIDFile]; ok {
+		h.ContainerIDFile = v
+	}
+	if v, ok := annotations[annotationRestartPolicy]; ok {
+		rp, err := restartPolicyFromAnnotation(v)
+		if err != nil {
+			return nil, err
+		}
+		h.RestartPolicy = rp
+	}
+	if v, ok := annotations[annotationIpcMode]; ok {
+		h.IpcMode = IpcMode(v)
+	}
+	if v, ok := annotations[annotationPidMode]; ok {
+		h.PidMode = PidMode(v)
+	}
+	if v, ok := annotations[annotationNetworkMode]; ok {
+		h.NetworkMode = NetworkMode(v)
+	}
+	if v, ok := annotations[annotationPrivileged]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &errInvalidParameter{err}
+		}
+		h.Privileged = b
+	}
+	if v, ok := annotations[annotationReadonlyRootfs]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &errInvalidParameter{err}
+		}
+		h.ReadonlyRootfs = b
+	}
+	if v, ok := annotations[annotationInit]; ok {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, &errInvalidParameter{err}
+		}
+		h.Init = &b
+	}
+	if v, ok := annotations[annotationRuntime]; ok {
+		h.Runtime = v
+	}
+	return h, nil
+}
+
+// restartPolicyToAnnotation encodes a RestartPolicy as "name" or, when a
+// retry count applies ("on-failure" and "on-unhealthy"), "name:count"
+// (e.g. "on-failure:5").
+func restartPolicyToAnnotation(rp RestartPolicy) string {
+	if rp.Name == "" {
+		return ""
+	}
+	if (rp.Name == RestartPolicyOnFailure || rp.Name == RestartPolicyOnUnhealthy) && rp.MaximumRetryCount != 0 {
+		return string(rp.Name) + ":" + strconv.Itoa(rp.MaximumRetryCount)
+	}
+	return string(rp.Name)
+}
+
+// restartPolicyFromAnnotation decodes a RestartPolicy from the
+// "name" or "name:count" form produced by restartPolicyToAnnotation.
+func restartPolicyFromAnnotation(s string) (RestartPolicy, error) {
+	name, countStr, hasCount := strings.Cut(s, ":")
+	rp := RestartPolicy{Name: RestartPolicyMode(name)}
+	if hasCount {
+		count, err := strconv.Atoi(countStr)
+		if err != nil {
+			return RestartPolicy{}, &errInvalidParameter{err}
+		}
+		rp.MaximumRetryCount = count
+	}
+	return rp, nil
+}
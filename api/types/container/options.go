@@ -0,0 +1,20 @@
+package container
+
+// LogsOptions holds parameters to filter logs with.
+type LogsOptions struct {
+	ShowStdout bool
+	ShowStderr bool
+	Since      string
+	Until      string
+	Timestamps bool
+	Follow     bool
+	Tail       string
+	Details    bool
+
+	// Filters further restricts which log lines are returned, for keys
+	// Swarm's log broker understands: "task" and "node" restrict the
+	// stream to the given task or node IDs, and "level" restricts it to
+	// lines at or above the given severity. It only applies to
+	// ServiceLogs.
+	Filters map[string][]string
+}
@@ -0,0 +1,163 @@
+package container
+
+import "time"
+
+// StatsResponse aggregates all types of stats of a container.
+type StatsResponse struct {
+	Read        time.Time   `json:"read"`
+	PreRead     time.Time   `json:"preread"`
+	PidsStats   PidsStats   `json:"pids_stats,omitempty"`
+	BlkioStats  BlkioStats  `json:"blkio_stats,omitempty"`
+	CPUStats    CPUStats    `json:"cpu_stats,omitempty"`
+	PreCPUStats CPUStats    `json:"precpu_stats,omitempty"` // "Pre"-CPU stats, used for calculating the cpu usage percentage
+	MemoryStats MemoryStats `json:"memory_stats,omitempty"`
+
+	// HugetlbStats reports hugepage usage, keyed by page size (e.g. "2MB",
+	// "1GB"). It is populated whenever the container's cgroup reports
+	// hugetlb usage; no daemon setting turns its collection on or off.
+	HugetlbStats map[string]HugetlbStat `json:"hugetlb_stats,omitempty"`
+
+	// RdmaStats reports RDMA resource usage, keyed by device name. Like
+	// HugetlbStats, it is populated whenever the container's cgroup
+	// reports RDMA usage; no daemon setting turns its collection on or off.
+	RdmaStats map[string]RdmaStat `json:"rdma_stats,omitempty"`
+
+	// Networks is only used by the raw connection API (docker API v1.20 and
+	// earlier); it's replaced by NetworkStats from API v1.21 onward.
+	Networks map[string]NetworkStats `json:"networks,omitempty"`
+}
+
+// HugetlbStat is one page size's worth of hugepage usage, as reported by
+// a cgroup's hugetlb controller.
+type HugetlbStat struct {
+	Usage    uint64 `json:"usage"`
+	MaxUsage uint64 `json:"max_usage"`
+	Failcnt  uint64 `json:"failcnt"`
+}
+
+// RdmaStat is one device's worth of RDMA resource usage, as reported by
+// a cgroup's rdma controller.
+type RdmaStat struct {
+	HcaHandles uint32 `json:"hca_handles"`
+	HcaObjects uint32 `json:"hca_objects"`
+}
+
+// PidsStats contains the stats of a container's pids.
+type PidsStats struct {
+	// Current is the number of pids in the cgroup.
+	Current uint64 `json:"current,omitempty"`
+	// Limit is the hard limit on the number of pids in the cgroup.
+	// A "Limit" of 0 means that there is no limit.
+	Limit uint64 `json:"limit,omitempty"`
+}
+
+// BlkioStatEntry is one entry of blkio stats, reported by cgroups.
+type BlkioStatEntry struct {
+	Major uint64 `json:"major"`
+	Minor uint64 `json:"minor"`
+	Op    string `json:"op"`
+	Value uint64 `json:"value"`
+}
+
+// BlkioStats stores all the blkio stats of a container.
+type BlkioStats struct {
+	IoServiceBytesRecursive []BlkioStatEntry `json:"io_service_bytes_recursive"`
+	IoServicedRecursive     []BlkioStatEntry `json:"io_serviced_recursive"`
+	IoQueuedRecursive       []BlkioStatEntry `json:"io_queue_recursive"`
+	IoServiceTimeRecursive  []BlkioStatEntry `json:"io_service_time_recursive"`
+	IoWaitTimeRecursive     []BlkioStatEntry `json:"io_wait_time_recursive"`
+	IoMergedRecursive       []BlkioStatEntry `json:"io_merged_recursive"`
+	IoTimeRecursive         []BlkioStatEntry `json:"io_time_recursive"`
+	SectorsRecursive        []BlkioStatEntry `json:"sectors_recursive"`
+
+	// PSI holds the cgroup v2 "io.pressure" PSI data, when available.
+	// It is nil on cgroup v1 hosts.
+	PSI *PressureStats `json:"psi,omitempty"`
+}
+
+// CPUUsage stores all CPU stats aggregated since container inception.
+type CPUUsage struct {
+	// TotalUsage is the total CPU time consumed, in nanoseconds.
+	TotalUsage uint64 `json:"total_usage"`
+	// PercpuUsage is the total CPU time consumed per core, in nanoseconds.
+	PercpuUsage []uint64 `json:"percpu_usage,omitempty"`
+	// UsageInKernelmode is the time spent by tasks in kernel mode, in nanoseconds.
+	UsageInKernelmode uint64 `json:"usage_in_kernelmode"`
+	// UsageInUsermode is the time spent by tasks in user mode, in nanoseconds.
+	UsageInUsermode uint64 `json:"usage_in_usermode"`
+}
+
+// ThrottlingData stores CPU throttling stats of a container.
+type ThrottlingData struct {
+	// Periods is the number of periods with throttling active.
+	Periods uint64 `json:"periods"`
+	// ThrottledPeriods is the number of periods when the container hit its throttling limit.
+	ThrottledPeriods uint64 `json:"throttled_periods"`
+	// ThrottledTime is the aggregate time the container was throttled for, in nanoseconds.
+	ThrottledTime uint64 `json:"throttled_time"`
+}
+
+// CPUStats aggregates and wraps all CPU related info of a container.
+type CPUStats struct {
+	CPUUsage       CPUUsage       `json:"cpu_usage"`
+	SystemUsage    uint64         `json:"system_cpu_usage,omitempty"`
+	OnlineCPUs     uint32         `json:"online_cpus,omitempty"`
+	ThrottlingData ThrottlingData `json:"throttling_data,omitempty"`
+
+	// PSI holds the cgroup v2 "cpu.pressure" PSI data, when available.
+	// It is nil on cgroup v1 hosts. Only the "some" line is meaningful
+	// for CPU pressure; "full" is always zero per the kernel's PSI doc.
+	PSI *PressureStats `json:"psi,omitempty"`
+}
+
+// MemoryStats aggregates all memory stats since container inception.
+type MemoryStats struct {
+	// Stats is the detailed memory stats, as reported by the cgroup.
+	// Its keys differ between cgroup v1 and v2 hosts.
+	Stats map[string]uint64 `json:"stats,omitempty"`
+
+	Usage    uint64 `json:"usage,omitempty"`
+	MaxUsage uint64 `json:"max_usage,omitempty"`
+	Failcnt  uint64 `json:"failcnt,omitempty"`
+	Limit    uint64 `json:"limit,omitempty"`
+
+	// Commit and CommitPeak are only used on Windows.
+	Commit            uint64 `json:"commitbytes,omitempty"`
+	CommitPeak        uint64 `json:"commitpeakbytes,omitempty"`
+	PrivateWorkingSet uint64 `json:"privateworkingset,omitempty"`
+
+	// PSI holds the cgroup v2 "memory.pressure" PSI data, when
+	// available. It is nil on cgroup v1 hosts.
+	PSI *PressureStats `json:"psi,omitempty"`
+}
+
+// NetworkStats aggregates the network stats of one container interface.
+type NetworkStats struct {
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
+}
+
+// PressureStats holds one resource's Pressure Stall Information, as
+// reported by a cgroup v2 "<resource>.pressure" file: the proportion of
+// time some (or, where the kernel reports it, all) tasks in the cgroup
+// were stalled on that resource, averaged over the last 10s/60s/300s,
+// plus the cumulative stalled time in microseconds.
+type PressureStats struct {
+	Some PressureData `json:"some"`
+	Full PressureData `json:"full"`
+}
+
+// PressureData is one "some"/"full" line of a PressureStats.
+type PressureData struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	// Total is the cumulative stalled time for this line, in microseconds.
+	Total uint64 `json:"total"`
+}
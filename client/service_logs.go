@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/moby/moby/api/types/container"
+)
+
+// unixTimestampRegex matches a Unix timestamp, with optional fractional
+// seconds, passed straight through to the daemon to parse.
+var unixTimestampRegex = regexp.MustCompile(`^\d+(\.\d+)?$`)
+
+// ServiceLogs returns the logs generated by a service in an io.ReadCloser.
+// It's up to the caller to close the stream.
+func (cli *Client) ServiceLogs(ctx context.Context, serviceID string, options container.LogsOptions) (io.ReadCloser, error) {
+	if strings.TrimSpace(serviceID) == "" {
+		return nil, fmt.Errorf("%w: value is empty", cerrdefs.ErrInvalidArgument)
+	}
+
+	query := url.Values{}
+	if options.ShowStdout {
+		query.Set("stdout", "1")
+	}
+	if options.ShowStderr {
+		query.Set("stderr", "1")
+	}
+
+	sinceTS, err := parseServiceLogsTimestamp("since", options.Since)
+	if err != nil {
+		return nil, err
+	}
+	untilTS, err := parseServiceLogsTimestamp("until", options.Until)
+	if err != nil {
+		return nil, err
+	}
+	if sinceTS != "" && untilTS != "" {
+		sinceF, _ := strconv.ParseFloat(sinceTS, 64)
+		untilF, _ := strconv.ParseFloat(untilTS, 64)
+		if untilF < sinceF {
+			return nil, fmt.Errorf("%w: until time (%s) cannot be before since time (%s)", cerrdefs.ErrInvalidArgument, options.Until, options.Since)
+		}
+	}
+	if sinceTS != "" {
+		query.Set("since", sinceTS)
+	}
+	if untilTS != "" {
+		query.Set("until", untilTS)
+	}
+
+	if options.Timestamps {
+		query.Set("timestamps", "1")
+	}
+	if options.Details {
+		query.Set("details", "1")
+	}
+	if options.Follow {
+		query.Set("follow", "1")
+	}
+	query.Set("tail", options.Tail)
+
+	if len(options.Filters) > 0 {
+		filterJSON, err := json.Marshal(options.Filters)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(filterJSON))
+	}
+
+	resp, err := cli.get(ctx, "/services/"+serviceID+"/logs", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// parseServiceLogsTimestamp parses a LogsOptions Since/Until value
+// client-side: as a duration relative to now, an RFC3339(Nano)
+// timestamp, or a Unix timestamp (optionally with fractional seconds)
+// passed straight through for the daemon to parse. An empty value
+// parses to "", leaving the corresponding query parameter unset.
+func parseServiceLogsTimestamp(field, value string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return unixTimestamp(time.Now().Add(-d)), nil
+	}
+	if strings.Contains(value, "T") {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return "", err
+		}
+		return unixTimestamp(t), nil
+	}
+	if unixTimestampRegex.MatchString(value) {
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid value for %q: failed to parse value as time or duration: %q", field, value)
+}
+
+func unixTimestamp(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), int64(t.Nanosecond()))
+}
@@ -38,6 +38,13 @@ func TestServiceLogsError(t *testing.T) {
 	_, err = client.ServiceLogs(context.Background(), "    ", container.LogsOptions{})
 	assert.Check(t, is.ErrorType(err, cerrdefs.IsInvalidArgument))
 	assert.Check(t, is.ErrorContains(err, "value is empty"))
+
+	_, err = client.ServiceLogs(context.Background(), "service_id", container.LogsOptions{
+		Since: "1136073600.000000001",
+		Until: "1000000000.000000000",
+	})
+	assert.Check(t, is.ErrorType(err, cerrdefs.IsInvalidArgument))
+	assert.Check(t, is.ErrorContains(err, "until time (1000000000.000000000) cannot be before since time (1136073600.000000001)"))
 }
 
 func TestServiceLogs(t *testing.T) {
@@ -94,6 +101,41 @@ func TestServiceLogs(t *testing.T) {
 			},
 			expectedError: `invalid value for "since": failed to parse value as time or duration: "invalid value"`,
 		},
+		{
+			options: container.LogsOptions{
+				// timestamp will be passed as is
+				Until: "1136073600.000000001",
+			},
+			expectedQueryParams: map[string]string{
+				"tail":  "",
+				"until": "1136073600.000000001",
+			},
+		},
+		{
+			options: container.LogsOptions{
+				Until: "invalid value",
+			},
+			expectedError: `invalid value for "until": failed to parse value as time or duration: "invalid value"`,
+		},
+		{
+			options: container.LogsOptions{
+				Since: "1136073600.000000001",
+				Until: "1000000000.000000000",
+			},
+			expectedError: "invalid argument: until time (1000000000.000000000) cannot be before since time (1136073600.000000001)",
+		},
+		{
+			options: container.LogsOptions{
+				Filters: map[string][]string{
+					"task":  {"task_id"},
+					"level": {"warn"},
+				},
+			},
+			expectedQueryParams: map[string]string{
+				"tail":    "",
+				"filters": `{"level":["warn"],"task":["task_id"]}`,
+			},
+		},
 	}
 	for _, logCase := range cases {
 		client := &Client{
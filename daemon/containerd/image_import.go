@@ -37,7 +37,9 @@ import (
 // If the platform is nil, the default host platform is used.
 // The message is used as the history comment.
 // Image configuration is derived from the dockerfile instructions in changes.
-func (i *ImageService) ImportImage(ctx context.Context, ref reference.Named, platform *ocispec.Platform, msg string, layerReader io.Reader, changes []string) (image.ID, error) {
+// With opts.Compression set to ZstdChunked, an uncompressed, xz or bzip2
+// archive is recompressed into zstd:chunked instead of gzip.
+func (i *ImageService) ImportImage(ctx context.Context, ref reference.Named, platform *ocispec.Platform, msg string, layerReader io.Reader, changes []string, opts ImportOptions) (image.ID, error) {
 	refString := ""
 	if ref != nil {
 		refString = ref.String()
@@ -67,28 +69,49 @@ func (i *ImageService) ImportImage(ctx context.Context, ref reference.Named, pla
 
 	cs := i.content
 
-	compressedDigest, uncompressedDigest, mt, err := saveArchive(ctx, cs, layerReader)
-	if err != nil {
-		logger.WithError(err).Debug("failed to write layer blob")
-		return "", err
+	var (
+		compressedDigest, uncompressedDigest digest.Digest
+		compressedRootfsDesc                 ocispec.Descriptor
+	)
+	if opts.EncryptedLayerMediaType != "" {
+		if opts.Crypto.Decrypt == nil {
+			return "", errdefs.InvalidParameter(errors.New("missing decryption key for encrypted layer"))
+		}
+		compressedRootfsDesc, uncompressedDigest, err = saveDecryptedArchive(ctx, cs, opts.EncryptedLayerMediaType, layerReader, opts.EncryptAnnotations, opts.Crypto, opts)
+		if err != nil {
+			logger.WithError(err).Debug("failed to decrypt and write layer blob")
+			return "", err
+		}
+		compressedDigest = compressedRootfsDesc.Digest
+	} else {
+		var mt string
+		compressedDigest, uncompressedDigest, mt, err = saveArchive(ctx, cs, layerReader, opts)
+		if err != nil {
+			logger.WithError(err).Debug("failed to write layer blob")
+			return "", err
+		}
+
+		size, err := fillUncompressedLabel(ctx, cs, compressedDigest, uncompressedDigest)
+		if err != nil {
+			logger.WithError(err).Debug("failed to set uncompressed label on the compressed blob")
+			return "", err
+		}
+		compressedRootfsDesc = ocispec.Descriptor{
+			MediaType: mt,
+			Digest:    compressedDigest,
+			Size:      size,
+		}
+		if annotations, err := readZstdChunkedTOC(ctx, cs, compressedDigest, size); err != nil {
+			logger.WithError(err).Debug("failed to inspect layer for a zstd:chunked table of contents")
+		} else if annotations != nil {
+			compressedRootfsDesc.Annotations = annotations
+		}
 	}
 	logger = logger.WithFields(log.Fields{
 		"compressedDigest":   compressedDigest,
 		"uncompressedDigest": uncompressedDigest,
 	})
 
-	size, err := fillUncompressedLabel(ctx, cs, compressedDigest, uncompressedDigest)
-	if err != nil {
-		logger.WithError(err).Debug("failed to set uncompressed label on the compressed blob")
-		return "", err
-	}
-
-	compressedRootfsDesc := ocispec.Descriptor{
-		MediaType: mt,
-		Digest:    compressedDigest,
-		Size:      size,
-	}
-
 	dockerCfg := containerConfigToDockerOCIImageConfig(imageConfig)
 	createdAt := time.Now()
 	config := imagespec.DockerOCIImage{
@@ -149,8 +172,10 @@ func (i *ImageService) ImportImage(ctx context.Context, ref reference.Named, pla
 		logger.WithError(err).Debug("failed to save image")
 		return "", err
 	}
+	clearPullLeaseExpiration(ctx, i.client.LeasesService(), manifestDesc.Digest)
 
-	err = i.unpackImage(ctx, i.StorageDriver(), img, manifestDesc)
+	sendProgress(opts.Progress, ProgressUpdate{Phase: ProgressPhaseUnpacking})
+	err = i.unpackImage(ctx, i.StorageDriver(), img, manifestDesc, opts.Crypto)
 	if err != nil {
 		logger.WithError(err).Debug("failed to unpack image")
 	} else {
@@ -162,7 +187,9 @@ func (i *ImageService) ImportImage(ctx context.Context, ref reference.Named, pla
 
 // saveArchive saves the archive from bufRd to the content store, compressing it if necessary.
 // Returns compressed blob digest, digest of the uncompressed data and media type of the stored blob.
-func saveArchive(ctx context.Context, cs content.Store, layerReader io.Reader) (digest.Digest, digest.Digest, string, error) {
+func saveArchive(ctx context.Context, cs content.Store, layerReader io.Reader, opts ImportOptions) (digest.Digest, digest.Digest, string, error) {
+	layerReader = &progressReader{r: layerReader, ch: opts.Progress, phase: ProgressPhaseDownloading}
+
 	// Wrap the reader in buffered reader to allow peeks.
 	p := pools.BufioReader32KPool
 	bufRd := p.Get(layerReader)
@@ -182,7 +209,7 @@ func saveArchive(ctx context.Context, cs content.Store, layerReader io.Reader) (
 			mediaType = ocispec.MediaTypeImageLayerZstd
 		}
 
-		compressedDigest, uncompressedDigest, err := writeCompressedBlob(ctx, cs, mediaType, bufRd)
+		compressedDigest, uncompressedDigest, err := writeCompressedBlob(ctx, cs, mediaType, bufRd, opts)
 		if err != nil {
 			return "", "", "", err
 		}
@@ -197,8 +224,16 @@ func saveArchive(ctx context.Context, cs content.Store, layerReader io.Reader) (
 		uncompressedReader = r
 		fallthrough
 	case compression.None:
+		if opts.Compression == ZstdChunked {
+			compressedDigest, uncompressedDigest, err := compressAndWriteChunkedBlob(ctx, cs, uncompressedReader)
+			if err != nil {
+				return "", "", "", err
+			}
+			return compressedDigest, uncompressedDigest, ocispec.MediaTypeImageLayerZstd, nil
+		}
+
 		mediaType := ocispec.MediaTypeImageLayerGzip
-		compressedDigest, uncompressedDigest, err := compressAndWriteBlob(ctx, cs, compression.Gzip, mediaType, uncompressedReader)
+		compressedDigest, uncompressedDigest, err := compressAndWriteBlob(ctx, cs, compression.Gzip, mediaType, uncompressedReader, opts)
 		if err != nil {
 			return "", "", "", err
 		}
@@ -210,15 +245,17 @@ func saveArchive(ctx context.Context, cs content.Store, layerReader io.Reader) (
 }
 
 // writeCompressedBlob writes the blob and simultaneously computes the digest of the uncompressed data.
-func writeCompressedBlob(ctx context.Context, cs content.Store, mediaType string, bufRd *bufio.Reader) (digest.Digest, digest.Digest, error) {
+func writeCompressedBlob(ctx context.Context, cs content.Store, mediaType string, bufRd *bufio.Reader, opts ImportOptions) (digest.Digest, digest.Digest, error) {
 	pr, pw := io.Pipe()
 	defer pw.Close()
 	defer pr.Close()
+	defer watchContextCancel(ctx, pr.CloseWithError, pw.CloseWithError)()
 
 	c := make(chan digest.Digest)
 	// Start copying the blob to the content store from the pipe and tee it to the pipe.
 	go func() {
-		compressedDigest, err := writeBlobAndReturnDigest(ctx, cs, mediaType, io.TeeReader(bufRd, pw))
+		compressedReader := &progressReader{r: io.TeeReader(bufRd, pw), ch: opts.Progress, phase: ProgressPhaseCompressing}
+		compressedDigest, err := writeBlobAndReturnDigest(ctx, cs, mediaType, compressedReader)
 		pw.CloseWithError(err)
 		c <- compressedDigest
 	}()
@@ -243,14 +280,16 @@ func writeCompressedBlob(ctx context.Context, cs content.Store, mediaType string
 	}
 
 	uncompressedDigest := digester.Digest()
+	sendProgress(opts.Progress, ProgressUpdate{Phase: ProgressPhaseDigesting, Digest: uncompressedDigest})
 	return compressedDigest, uncompressedDigest, nil
 }
 
 // compressAndWriteBlob compresses the uncompressedReader and stores it in the content store.
-func compressAndWriteBlob(ctx context.Context, cs content.Store, comp compression.Compression, mediaType string, uncompressedLayerReader io.Reader) (digest.Digest, digest.Digest, error) {
+func compressAndWriteBlob(ctx context.Context, cs content.Store, comp compression.Compression, mediaType string, uncompressedLayerReader io.Reader, opts ImportOptions) (digest.Digest, digest.Digest, error) {
 	pr, pw := io.Pipe()
 	defer pr.Close()
 	defer pw.Close()
+	defer watchContextCancel(ctx, pr.CloseWithError, pw.CloseWithError)()
 
 	compressor, err := compression.CompressStream(pw, comp)
 	if err != nil {
@@ -260,7 +299,8 @@ func compressAndWriteBlob(ctx context.Context, cs content.Store, comp compressio
 	writeChan := make(chan digest.Digest)
 	// Start copying the blob to the content store from the pipe.
 	go func() {
-		dgst, err := writeBlobAndReturnDigest(ctx, cs, mediaType, pr)
+		compressedReader := &progressReader{r: pr, ch: opts.Progress, phase: ProgressPhaseCompressing}
+		dgst, err := writeBlobAndReturnDigest(ctx, cs, mediaType, compressedReader)
 		pr.CloseWithError(err)
 		writeChan <- dgst
 	}()
@@ -281,7 +321,9 @@ func compressAndWriteBlob(ctx context.Context, cs content.Store, comp compressio
 		return "", "", errdefs.System(err)
 	}
 
-	return compressedDigest, uncompressedDigester.Digest(), err
+	uncompressedDigest := uncompressedDigester.Digest()
+	sendProgress(opts.Progress, ProgressUpdate{Phase: ProgressPhaseDigesting, Digest: uncompressedDigest})
+	return compressedDigest, uncompressedDigest, err
 }
 
 // writeBlobAndReturnDigest writes a blob to the content store and returns the digest.
@@ -294,8 +336,16 @@ func writeBlobAndReturnDigest(ctx context.Context, cs content.Store, mt string,
 }
 
 // unpackImage unpacks the platform-specific manifest of a image into the snapshotter.
-func (i *ImageService) unpackImage(ctx context.Context, snapshotter string, img c8dimages.Image, manifestDesc ocispec.Descriptor) error {
-	c8dImg, err := i.NewImageManifest(ctx, img, manifestDesc)
+func (i *ImageService) unpackImage(ctx context.Context, snapshotter string, img c8dimages.Image, manifestDesc ocispec.Descriptor, cc CryptoConfig) error {
+	unpackDesc, err := decryptManifestForUnpack(ctx, i.content, manifestDesc, cc)
+	if err != nil {
+		return err
+	}
+
+	unpackImg := img
+	unpackImg.Target = unpackDesc
+
+	c8dImg, err := i.NewImageManifest(ctx, unpackImg, unpackDesc)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,360 @@
+package containerd
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/moby/go-archive/compression"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// ChunkedCompression selects how ImportOptions asks ImportImage/saveArchive
+// to compress an uncompressed input layer.
+type ChunkedCompression int
+
+const (
+	// compressionDefault leaves the existing gzip-everything-uncompressed
+	// behavior in place.
+	compressionDefault ChunkedCompression = iota
+	// ZstdChunked asks for a zstd layer carrying a table-of-contents
+	// footer, so partial-pull/lazy-pull snapshotters can fetch individual
+	// files out of the layer instead of the whole blob.
+	ZstdChunked
+)
+
+// ImportOptions configures ImportImage beyond its required arguments;
+// LoadImage does not take an ImportOptions. It grows as more of the surrounding import/export surface
+// (encryption, progress reporting, ...) gets threaded through.
+type ImportOptions struct {
+	// Compression selects how a plain (uncompressed) input layer is
+	// recompressed. It has no effect on a gzip or zstd input, which
+	// saveArchive preserves as-is; a bzip2 or xz input is decompressed
+	// and then recompressed the same way as an uncompressed one.
+	Compression ChunkedCompression
+
+	// EncryptedLayerMediaType, if set, asserts that layerReader is
+	// already an OCI encrypted layer of this media type (e.g.
+	// "application/vnd.oci.image.layer.v1.tar+gzip+encrypted") rather
+	// than a plain archive: saveArchive's compression sniffing doesn't
+	// apply to ciphertext, so ImportImage takes a separate decrypt path
+	// when this is set.
+	EncryptedLayerMediaType string
+	// EncryptAnnotations carries the org.opencontainers.image.enc.*
+	// annotations describing how the layer named by
+	// EncryptedLayerMediaType was encrypted (key wraps, key IDs, ...),
+	// as recorded on its original descriptor.
+	EncryptAnnotations map[string]string
+	// Crypto carries the decrypt (and, for a future export path, encrypt)
+	// configuration ocicrypt needs to unwrap EncryptedLayerMediaType.
+	Crypto CryptoConfig
+
+	// Progress, if set, receives best-effort ProgressUpdates as
+	// ImportImage works through its phases. Sends are
+	// non-blocking: a slow consumer drops updates rather than stalling
+	// the import.
+	Progress chan<- ProgressUpdate
+}
+
+// zstdChunked annotation keys, written onto a layer descriptor when its
+// blob carries (or was given) a zstd:chunked table of contents, mirroring
+// the annotations containers/storage's chunked puller looks for so an
+// image imported here remains eligible for partial pulls rather than
+// forcing a re-push to gain that property.
+const (
+	zstdChunkedAnnotationManifestChecksum = "io.github.containers.zstd-chunked.manifest-checksum"
+	zstdChunkedAnnotationManifestPosition = "io.github.containers.zstd-chunked.manifest-position"
+	zstdChunkedAnnotationUncompressedSize = "io.github.containers.zstd-chunked.uncompressed-size"
+	zstdChunkedAnnotationTOCDigest        = "io.github.containers.zstd-chunked.toc-digest"
+)
+
+// zstdChunkedSkippableFrameMagic is the lowest of the 16 magic numbers
+// the zstd frame format reserves for "skippable frames" (0x184D2A50 -
+// 0x184D2A5F): a frame any zstd decoder must skip over without
+// understanding its contents. zstd:chunked style formats append one at
+// the end of the stream to carry an out-of-band table of contents; we
+// reserve this specific magic for the TOC footer we read and write.
+//
+// The footer's JSON payload schema below is this package's own, not a
+// byte-for-byte reimplementation of containers/storage's internal
+// zstd-chunked footer (that exact layout isn't available to verify
+// against in this environment) -- but it lives inside a real,
+// spec-compliant zstd skippable frame, so any zstd decoder, chunked-aware
+// or not, can still parse the rest of the stream around it.
+const zstdChunkedSkippableFrameMagic = 0x184D2A50
+
+// zstdChunkedTOCEntry records one tar entry's position in both the
+// decompressed layer stream (Offset/Size) and the compressed blob itself
+// (CompressedOffset/CompressedSize), so a chunked-aware puller can either
+// map a file path to the bytes it needs after unpacking, or fetch just
+// that entry's independent zstd frame directly out of the compressed
+// blob with an HTTP Range request, without touching its neighbors.
+type zstdChunkedTOCEntry struct {
+	Name             string `json:"name"`
+	Offset           int64  `json:"offset"`
+	Size             int64  `json:"size"`
+	Digest           string `json:"digest"`
+	CompressedOffset int64  `json:"compressedOffset"`
+	CompressedSize   int64  `json:"compressedSize"`
+}
+
+type zstdChunkedTOC struct {
+	Version          int                   `json:"version"`
+	UncompressedSize int64                 `json:"uncompressedSize"`
+	Entries          []zstdChunkedTOCEntry `json:"entries"`
+}
+
+// readZstdChunkedTOC looks for a zstdChunkedSkippableFrameMagic
+// skippable frame at the very end of the blob stored under dgst and, if
+// found, returns the descriptor annotations that record it. It returns a
+// nil map (not an error) if the blob simply isn't zstd:chunked, since
+// that's the common case for layers saveArchive didn't itself produce.
+func readZstdChunkedTOC(ctx context.Context, cs content.Store, dgst digest.Digest, size int64) (map[string]string, error) {
+	const maxFooterWindow = 1 << 20 // footers are small; 1MiB bounds the read generously
+	window := size
+	if window > maxFooterWindow {
+		window = maxFooterWindow
+	}
+
+	ra, err := cs.ReaderAt(ctx, ocispec.Descriptor{Digest: dgst, Size: size})
+	if err != nil {
+		return nil, errors.Wrap(err, "opening blob to look for a zstd:chunked footer")
+	}
+	defer ra.Close()
+
+	buf := make([]byte, window)
+	if _, err := ra.ReadAt(buf, size-window); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "reading trailing bytes to look for a zstd:chunked footer")
+	}
+
+	frameOffsetInWindow, payload, ok := findTrailingSkippableFrame(buf)
+	if !ok {
+		return nil, nil
+	}
+	frameOffset := size - window + int64(frameOffsetInWindow)
+
+	var toc zstdChunkedTOC
+	if err := json.Unmarshal(payload, &toc); err != nil {
+		// A skippable frame at the right magic that isn't our TOC JSON is
+		// someone else's data; not an error, just nothing to annotate.
+		return nil, nil
+	}
+
+	annotations := map[string]string{
+		zstdChunkedAnnotationManifestChecksum: digest.FromBytes(payload).String(),
+		zstdChunkedAnnotationManifestPosition: fmtPosition(frameOffset, int64(len(payload))),
+		zstdChunkedAnnotationTOCDigest:        digest.FromBytes(payload).String(),
+	}
+	if toc.UncompressedSize > 0 {
+		annotations[zstdChunkedAnnotationUncompressedSize] = fmtInt(toc.UncompressedSize)
+	}
+	return annotations, nil
+}
+
+// findTrailingSkippableFrame scans buf (the tail of a blob) for the last
+// well-formed zstdChunkedSkippableFrameMagic frame that ends exactly at
+// the end of buf, and returns its offset within buf and its payload.
+func findTrailingSkippableFrame(buf []byte) (int, []byte, bool) {
+	const headerSize = 8 // 4-byte magic + 4-byte little-endian payload size
+	for i := len(buf) - headerSize; i >= 0; i-- {
+		magic := binary.LittleEndian.Uint32(buf[i : i+4])
+		if magic != zstdChunkedSkippableFrameMagic {
+			continue
+		}
+		payloadSize := binary.LittleEndian.Uint32(buf[i+4 : i+8])
+		frameEnd := i + headerSize + int(payloadSize)
+		if frameEnd != len(buf) {
+			continue
+		}
+		return i, buf[i+headerSize : frameEnd], true
+	}
+	return 0, nil, false
+}
+
+func writeSkippableFrame(w io.Writer, payload []byte) error {
+	header := make([]byte, 8)
+	binary.LittleEndian.PutUint32(header[0:4], zstdChunkedSkippableFrameMagic)
+	binary.LittleEndian.PutUint32(header[4:8], uint32(len(payload)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// compressAndWriteChunkedBlob zstd-compresses uncompressedLayerReader (a
+// tar stream) as a sequence of independent zstd frames, one per tar
+// entry, rather than one continuous frame: each entry's header, content
+// and block padding are re-serialized through their own
+// compression.CompressStream call, so the resulting frame can be
+// decompressed entirely on its own. The zstd format defines concatenated
+// frames as transparently decoding to the concatenation of their
+// contents, so a plain decompressor still reconstructs the whole tar
+// stream unchanged; a chunked-aware puller instead uses the
+// CompressedOffset/CompressedSize recorded in the appended zstd:chunked
+// TOC footer to fetch one entry's compressed bytes with an HTTP Range
+// request and decompress only that frame, without touching its
+// neighbors.
+func compressAndWriteChunkedBlob(ctx context.Context, cs content.Store, uncompressedLayerReader io.Reader) (digest.Digest, digest.Digest, error) {
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	writeChan := make(chan digest.Digest, 1)
+	go func() {
+		dgst, err := writeBlobAndReturnDigest(ctx, cs, ocispec.MediaTypeImageLayerZstd, pr)
+		pr.CloseWithError(err)
+		writeChan <- dgst
+	}()
+
+	uncompressedDigester := digest.Canonical.Digester()
+	cr := &countingReader{r: io.TeeReader(uncompressedLayerReader, uncompressedDigester.Hash())}
+	cw := &countingWriter{w: pw}
+
+	entries, err := writeChunkedFrames(tar.NewReader(cr), cr, cw)
+	if err == nil {
+		toc := zstdChunkedTOC{Version: 1, UncompressedSize: cr.n, Entries: entries}
+		var payload []byte
+		payload, err = json.Marshal(toc)
+		if err == nil {
+			// The TOC footer is a skippable frame written straight to
+			// the output, not zstd-compressed like the entry frames
+			// above: a chunked-aware reader must be able to find and
+			// read it from the compressed bytes alone, without
+			// decompressing anything first.
+			err = writeSkippableFrame(cw, payload)
+		}
+	}
+	pw.CloseWithError(err)
+
+	compressedDigest := <-writeChan
+	if err != nil {
+		return "", "", errors.Wrap(err, "writing zstd:chunked blob")
+	}
+	return compressedDigest, uncompressedDigester.Digest(), nil
+}
+
+// countingReader tracks how many bytes have been read through it, so
+// writeChunkedFrames can record each tar entry's offset in the raw
+// (header-and-padding-included) uncompressed byte stream rather than
+// just its content offset.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter tracks how many bytes have been written through it, so
+// writeChunkedFrames can record each entry's zstd frame as a compressed
+// byte range within the blob being written to cw.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeChunkedFrames reads tr (backed by cr, so raw uncompressed offsets
+// are available) and, for every entry, re-serializes its header and
+// content through a fresh zstd frame written to cw, returning a TOC
+// entry for every regular file. Each frame is fully closed (not merely
+// flushed) before the next one starts, so every entry's compressed bytes
+// stand alone as a complete, independently decodable frame.
+func writeChunkedFrames(tr *tar.Reader, cr *countingReader, cw *countingWriter) ([]zstdChunkedTOCEntry, error) {
+	var entries []zstdChunkedTOCEntry
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			// archive/tar's own Writer.Close would emit this as the
+			// very last thing it wrote; since no entry ever closes its
+			// tar.Writer (see the Flush comment below), write it here
+			// instead so the reassembled stream still ends with a
+			// standard end-of-archive marker.
+			return entries, writeEndOfArchiveFrame(cw)
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "reading tar entry while building zstd:chunked blob")
+		}
+		startOffset := cr.n
+
+		frameStart := cw.n
+		compressor, err := compression.CompressStream(cw, compression.Zstd)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating zstd compressor for chunk frame")
+		}
+
+		// tw writes into this entry's own compressor, not cw directly,
+		// and is flushed rather than closed below: tar.Writer.Close
+		// would append the two-block end-of-archive trailer, which must
+		// only appear once, at the very end of the whole reassembled
+		// tar stream, not after every entry.
+		tw := tar.NewWriter(compressor)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return nil, errors.Wrapf(err, "writing tar header for %s", hdr.Name)
+		}
+
+		var entry *zstdChunkedTOCEntry
+		if hdr.Typeflag == tar.TypeReg {
+			h := digest.Canonical.Digester()
+			if _, err := io.Copy(io.MultiWriter(tw, h.Hash()), tr); err != nil {
+				return nil, errors.Wrapf(err, "reading %s while building zstd:chunked blob", hdr.Name)
+			}
+			entry = &zstdChunkedTOCEntry{
+				Name:   hdr.Name,
+				Offset: startOffset,
+				Size:   hdr.Size,
+				Digest: h.Digest().String(),
+			}
+		}
+
+		if err := tw.Flush(); err != nil {
+			return nil, errors.Wrapf(err, "flushing tar entry for %s", hdr.Name)
+		}
+		if err := compressor.Close(); err != nil {
+			return nil, errors.Wrapf(err, "closing zstd frame for %s", hdr.Name)
+		}
+
+		if entry != nil {
+			entry.CompressedOffset = frameStart
+			entry.CompressedSize = cw.n - frameStart
+			entries = append(entries, *entry)
+		}
+	}
+}
+
+// writeEndOfArchiveFrame writes the two 512-byte zero blocks that mark
+// the end of a tar archive, as its own independent zstd frame, so the
+// reassembled stream remains a standard, complete tar archive even
+// though no individual entry's tar.Writer was ever closed.
+func writeEndOfArchiveFrame(cw *countingWriter) error {
+	compressor, err := compression.CompressStream(cw, compression.Zstd)
+	if err != nil {
+		return errors.Wrap(err, "creating zstd compressor for end-of-archive frame")
+	}
+	if _, err := compressor.Write(make([]byte, 2*512)); err != nil {
+		return errors.Wrap(err, "writing end-of-archive marker")
+	}
+	return compressor.Close()
+}
+
+func fmtPosition(offset, length int64) string {
+	return strconv.FormatInt(offset, 10) + ":" + strconv.FormatInt(length, 10)
+}
+
+func fmtInt(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
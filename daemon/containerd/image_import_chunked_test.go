@@ -0,0 +1,76 @@
+package containerd
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/moby/go-archive/compression"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func TestWriteChunkedFramesRoundTrips(t *testing.T) {
+	entries := []struct{ Name, Body string }{
+		{"a.txt", "hello"},
+		{"b.txt", "a longer bit of file content for the second entry"},
+	}
+
+	var rawTar bytes.Buffer
+	tw := tar.NewWriter(&rawTar)
+	for _, e := range entries {
+		assert.NilError(t, tw.WriteHeader(&tar.Header{Name: e.Name, Mode: 0o644, Size: int64(len(e.Body)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(e.Body))
+		assert.NilError(t, err)
+	}
+	assert.NilError(t, tw.Close())
+
+	cr := &countingReader{r: bytes.NewReader(rawTar.Bytes())}
+	var out bytes.Buffer
+	cw := &countingWriter{w: &out}
+
+	toc, err := writeChunkedFrames(tar.NewReader(cr), cr, cw)
+	assert.NilError(t, err)
+	assert.Check(t, is.Len(toc, len(entries)))
+
+	// Decompressing the whole blob must reproduce the original tar stream,
+	// including a standard end-of-archive trailer, even though no
+	// individual entry's tar.Writer was ever closed.
+	decompressed, err := compression.DecompressStream(bytes.NewReader(out.Bytes()))
+	assert.NilError(t, err)
+	defer decompressed.Close()
+
+	tr := tar.NewReader(decompressed)
+	for i, e := range entries {
+		hdr, err := tr.Next()
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(hdr.Name, e.Name))
+		body, err := io.ReadAll(tr)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(string(body), e.Body))
+		assert.Check(t, is.Equal(toc[i].Name, e.Name))
+	}
+	_, err = tr.Next()
+	assert.Check(t, is.ErrorIs(err, io.EOF))
+
+	// Each TOC entry's CompressedOffset/CompressedSize must bound a
+	// standalone-decodable zstd frame: decompressing just that byte range,
+	// with none of its neighbors, reproduces that entry's header and
+	// content on its own. This is what lets a chunked-aware puller fetch
+	// one file with an HTTP Range request instead of the whole blob.
+	for i, e := range toc {
+		frame := out.Bytes()[e.CompressedOffset : e.CompressedOffset+e.CompressedSize]
+		fr, err := compression.DecompressStream(bytes.NewReader(frame))
+		assert.NilError(t, err)
+
+		ftr := tar.NewReader(fr)
+		hdr, err := ftr.Next()
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(hdr.Name, entries[i].Name))
+		body, err := io.ReadAll(ftr)
+		assert.NilError(t, err)
+		assert.Check(t, is.Equal(string(body), entries[i].Body))
+		fr.Close()
+	}
+}
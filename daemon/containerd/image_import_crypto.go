@@ -0,0 +1,138 @@
+package containerd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+
+	"github.com/containerd/containerd/v2/core/content"
+	c8dimages "github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/platforms"
+	"github.com/containers/ocicrypt"
+	ocicryptconfig "github.com/containers/ocicrypt/config"
+	"github.com/moby/moby/v2/errdefs"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// CryptoConfig mirrors ocicrypt's own CryptoConfig: a decrypt half used
+// on import/unpack and an encrypt half reserved for a future export
+// path, kept as one struct so ImportOptions only needs a single field
+// for both.
+type CryptoConfig struct {
+	Decrypt *ocicryptconfig.DecryptConfig
+	Encrypt *ocicryptconfig.EncryptConfig
+}
+
+// isEncryptedMediaType reports whether mt is one of the OCI encrypted
+// layer media types, which ocicrypt forms by appending "+encrypted" to
+// the plain layer media type it wraps.
+func isEncryptedMediaType(mt string) bool {
+	return strings.HasSuffix(mt, "+encrypted")
+}
+
+// saveDecryptedArchive decrypts layerReader -- an OCI-encrypted layer of
+// the given media type, with the org.opencontainers.image.enc.*
+// annotations its source descriptor carried -- and hands the resulting
+// plaintext to the normal saveArchive path, so its compression is
+// detected and stored the same way an unencrypted import would be. It
+// returns the stored (plaintext) layer descriptor and its uncompressed
+// digest, for the manifest's RootFS.DiffIDs.
+func saveDecryptedArchive(ctx context.Context, cs content.Store, mt string, layerReader io.Reader, annotations map[string]string, cc CryptoConfig, opts ImportOptions) (ocispec.Descriptor, digest.Digest, error) {
+	if cc.Decrypt == nil {
+		return ocispec.Descriptor{}, "", errdefs.InvalidParameter(errors.New("missing decryption key for encrypted layer"))
+	}
+
+	desc := ocispec.Descriptor{MediaType: mt, Annotations: annotations}
+	plain, _, err := ocicrypt.DecryptLayer(cc.Decrypt, layerReader, desc, false)
+	if err != nil {
+		return ocispec.Descriptor{}, "", errdefs.InvalidParameter(errors.Wrap(err, "decrypting layer on import"))
+	}
+
+	compressedDigest, uncompressedDigest, plainMT, err := saveArchive(ctx, cs, plain, opts)
+	if err != nil {
+		return ocispec.Descriptor{}, "", err
+	}
+	size, err := fillUncompressedLabel(ctx, cs, compressedDigest, uncompressedDigest)
+	if err != nil {
+		return ocispec.Descriptor{}, "", err
+	}
+	return ocispec.Descriptor{MediaType: plainMT, Digest: compressedDigest, Size: size}, uncompressedDigest, nil
+}
+
+// decryptManifestForUnpack returns a manifest descriptor unpackImage can
+// safely hand to Unpack: every encrypted layer in manifestDesc's manifest
+// is decrypted up front and re-stored as its own plaintext blob, with the
+// manifest rewritten to reference the plaintext descriptors instead.
+// Unpack itself only knows how to read whatever the snapshotter's differ
+// is given; containerd would otherwise only decrypt layers through
+// imgcrypt's diff-service registration at daemon startup, which is out of
+// scope for a per-call helper, so doing the decryption here rather than
+// relying on that registration is what makes unpack actually work with a
+// correct key instead of just failing with a clearer error than before.
+//
+// manifestDesc itself, and the image metadata pointing at it, are left
+// untouched: that's the encrypted manifest that should still be pushed
+// or re-exported, so only this local copy used for Unpack gets plaintext
+// layers. If manifestDesc has no encrypted layers at all, it's returned
+// unchanged and nothing new is written.
+func decryptManifestForUnpack(ctx context.Context, cs content.Store, manifestDesc ocispec.Descriptor, cc CryptoConfig) (ocispec.Descriptor, error) {
+	manifest, err := c8dimages.Manifest(ctx, cs, manifestDesc, platforms.Default())
+	if err != nil {
+		return ocispec.Descriptor{}, errors.Wrap(err, "reading manifest to decrypt its layers")
+	}
+
+	var changed bool
+	layers := make([]ocispec.Descriptor, len(manifest.Layers))
+	for idx, l := range manifest.Layers {
+		if !isEncryptedMediaType(l.MediaType) {
+			layers[idx] = l
+			continue
+		}
+		changed = true
+		plainDesc, err := decryptLayerBlob(ctx, cs, l, cc)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		layers[idx] = plainDesc
+	}
+	if !changed {
+		return manifestDesc, nil
+	}
+
+	manifest.Layers = layers
+	return storeJson(ctx, cs, manifest.MediaType, manifest, nil)
+}
+
+// decryptLayerBlob decrypts the encrypted layer stored under desc and
+// writes the resulting plaintext as its own content-addressed blob,
+// returning a descriptor for it with the "+encrypted" suffix stripped
+// from its media type and its size updated to match the plaintext.
+func decryptLayerBlob(ctx context.Context, cs content.Store, desc ocispec.Descriptor, cc CryptoConfig) (ocispec.Descriptor, error) {
+	if cc.Decrypt == nil {
+		return ocispec.Descriptor{}, errdefs.InvalidParameter(errors.Errorf("missing decryption key for encrypted layer %s", desc.Digest))
+	}
+
+	encrypted, err := content.ReadBlob(ctx, cs, desc)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.Wrapf(err, "reading encrypted layer %s", desc.Digest)
+	}
+
+	plain, _, err := ocicrypt.DecryptLayer(cc.Decrypt, bytes.NewReader(encrypted), desc, false)
+	if err != nil {
+		return ocispec.Descriptor{}, errdefs.InvalidParameter(errors.Wrapf(err, "decrypting layer %s", desc.Digest))
+	}
+
+	plainMT := strings.TrimSuffix(desc.MediaType, "+encrypted")
+	plainDigest, err := writeBlobAndReturnDigest(ctx, cs, plainMT, plain)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	info, err := cs.Info(ctx, plainDigest)
+	if err != nil {
+		return ocispec.Descriptor{}, errors.Wrap(err, "reading decrypted layer size")
+	}
+	return ocispec.Descriptor{MediaType: plainMT, Digest: plainDigest, Size: info.Size}, nil
+}
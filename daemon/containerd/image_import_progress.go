@@ -0,0 +1,100 @@
+package containerd
+
+import (
+	"context"
+	"io"
+
+	"github.com/containerd/containerd/v2/core/leases"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/containerd/log"
+	"github.com/moby/moby/v2/daemon/images"
+	digest "github.com/opencontainers/go-digest"
+)
+
+// ProgressPhase labels which stage of an import a ProgressUpdate came
+// from, so a caller rendering them (e.g. as the jsonmessage stream
+// POST /images/create already produces for pulls) can tell a slow
+// network read apart from a slow local compression pass.
+type ProgressPhase string
+
+const (
+	ProgressPhaseDownloading ProgressPhase = "downloading"
+	ProgressPhaseCompressing ProgressPhase = "compressing"
+	ProgressPhaseDigesting   ProgressPhase = "digesting"
+	ProgressPhaseUnpacking   ProgressPhase = "unpacking"
+)
+
+// ProgressUpdate is one point-in-time snapshot of an in-progress
+// ImportImage call, sent best-effort (dropped rather than
+// blocking the import) to ImportOptions.Progress.
+type ProgressUpdate struct {
+	Phase     ProgressPhase
+	BytesRead int64
+	// Digest is only set on the final update of a phase that produces
+	// one (Digesting), once the running hash is complete.
+	Digest digest.Digest
+}
+
+// sendProgress is a no-op if ch is nil, so every call site can report
+// progress unconditionally without a nil check of its own.
+func sendProgress(ch chan<- ProgressUpdate, update ProgressUpdate) {
+	if ch == nil {
+		return
+	}
+	select {
+	case ch <- update:
+	default:
+		// A caller not keeping up with progress updates shouldn't stall
+		// the import; the next update will supersede this one anyway.
+	}
+}
+
+// progressReader wraps an io.Reader, reporting cumulative bytes read
+// for the given phase to ch as it's read. It never reports an error of
+// its own; Read's error is left for the caller to handle.
+type progressReader struct {
+	r     io.Reader
+	ch    chan<- ProgressUpdate
+	phase ProgressPhase
+	n     int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.n += int64(n)
+	sendProgress(p.ch, ProgressUpdate{Phase: p.phase, BytesRead: p.n})
+	return n, err
+}
+
+// clearPullLeaseExpiration tells the daemon/images orphaned-pull-lease
+// reaper that the image at dgst is safely committed, by clearing the
+// gc.expire label on its moby-image- lease (see
+// images.ClearPullLeaseExpiration), if one exists. It's best-effort: a
+// missing lease (the common case for ImportImage/LoadImage, which don't
+// themselves create a per-pull lease the way a real distribution pull
+// would) isn't an error worth failing the import over.
+func clearPullLeaseExpiration(ctx context.Context, lm leases.Manager, dgst digest.Digest) {
+	if err := images.ClearPullLeaseExpiration(ctx, lm, dgst); err != nil && !cerrdefs.IsNotFound(err) {
+		log.G(ctx).WithError(err).WithField("digest", dgst).Debug("failed to clear pull lease expiration")
+	}
+}
+
+// watchContextCancel closes every closer with ctx.Err() if ctx is
+// canceled before the returned stop function is called, so a goroutine
+// blocked on a pipe read/write unblocks promptly instead of leaking
+// until the pipe's other end happens to close on its own. Callers
+// should defer the returned stop function once the pipe is done with
+// normally, to avoid leaking the watcher goroutine.
+func watchContextCancel(ctx context.Context, closers ...func(error)) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			for _, c := range closers {
+				c(ctx.Err())
+			}
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,335 @@
+package containerd
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	c8dimages "github.com/containerd/containerd/v2/core/images"
+	"github.com/containerd/log"
+	"github.com/containerd/platforms"
+	"github.com/distribution/reference"
+	imagespec "github.com/moby/docker-image-spec/specs-go/v1"
+	"github.com/moby/go-archive/compression"
+	"github.com/moby/moby/api/types/events"
+	"github.com/moby/moby/v2/daemon/internal/image"
+	"github.com/moby/moby/v2/errdefs"
+	"github.com/moby/moby/v2/pkg/pools"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/pkg/errors"
+)
+
+// dockerSaveManifestEntry is one entry of a `docker save` v1.2
+// manifest.json: one image's config and the paths of its layers inside
+// the archive, in the same order as the config's RootFS.DiffIDs.
+type dockerSaveManifestEntry struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// LoadImage is the `docker load` counterpart to ImportImage: rather than
+// treating the archive as a single rootfs layer with a synthesized
+// config, it sniffs the tar stream for a `docker save` v1.2 archive
+// (manifest.json, one <config>.json and one or more layer tars per
+// image) or an OCI image layout (oci-layout, index.json,
+// blobs/<algo>/<digest>), walks it into the content store in a single
+// pass, and recreates every image the archive describes -- preserving
+// the DiffIDs and history already recorded in each image's config rather
+// than fabricating a one-layer history the way ImportImage does for a
+// bare rootfs.
+func (i *ImageService) LoadImage(ctx context.Context, archiveReader io.Reader, platform *ocispec.Platform) ([]image.ID, error) {
+	logger := log.G(ctx)
+
+	ctx, release, err := i.client.WithLease(ctx)
+	if err != nil {
+		return nil, errdefs.System(err)
+	}
+	defer func() {
+		if err := release(context.WithoutCancel(ctx)); err != nil {
+			logger.WithError(err).Warn("failed to release lease created for load")
+		}
+	}()
+
+	if platform == nil {
+		def := platforms.DefaultSpec()
+		platform = &def
+	}
+
+	cs := i.content
+	blobs := make(map[string]ocispec.Descriptor)
+	var manifestJSON, indexJSON []byte
+	var isOCILayout bool
+
+	tr := tar.NewReader(archiveReader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errdefs.InvalidParameter(errors.Wrap(err, "reading image archive"))
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := path.Clean(hdr.Name)
+
+		switch name {
+		case "manifest.json":
+			manifestJSON, err = io.ReadAll(tr)
+		case "index.json":
+			indexJSON, err = io.ReadAll(tr)
+		case "oci-layout":
+			isOCILayout = true
+		default:
+			desc, werr := loadBlobEntry(ctx, cs, tr, hdr.Size)
+			if werr == nil {
+				blobs[name] = desc
+			}
+			err = werr
+		}
+		if err != nil {
+			return nil, errdefs.Unknown(errors.Wrapf(err, "reading %s from image archive", name))
+		}
+	}
+
+	switch {
+	case isOCILayout && indexJSON != nil:
+		return i.loadOCILayout(ctx, blobs, indexJSON)
+	case manifestJSON != nil:
+		return i.loadDockerSave(ctx, blobs, manifestJSON)
+	default:
+		return nil, errdefs.InvalidParameter(errors.New("unrecognized image archive: no manifest.json or oci-layout/index.json found"))
+	}
+}
+
+// loadBlobEntry detects the compression of one archive entry, writes it
+// to the content store as-is, and returns its descriptor. Layers in
+// both supported formats are already content-addressed the same way a
+// registry would serve them, so there's no need to recompress.
+func loadBlobEntry(ctx context.Context, cs content.Store, r io.Reader, size int64) (ocispec.Descriptor, error) {
+	p := pools.BufioReader32KPool
+	bufRd := p.Get(r)
+	defer p.Put(bufRd)
+
+	bs, err := bufRd.Peek(10)
+	if err != nil && err != io.EOF {
+		return ocispec.Descriptor{}, err
+	}
+
+	mt := ocispec.MediaTypeImageLayer
+	switch compression.Detect(bs) {
+	case compression.Gzip:
+		mt = ocispec.MediaTypeImageLayerGzip
+	case compression.Zstd:
+		mt = ocispec.MediaTypeImageLayerZstd
+	}
+
+	dgst, err := writeBlobAndReturnDigest(ctx, cs, mt, bufRd)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return ocispec.Descriptor{MediaType: mt, Digest: dgst, Size: size}, nil
+}
+
+// loadDockerSave recreates every image described by a `docker save`
+// v1.2 manifest.json, whose Config and Layers entries are archive paths
+// already present in blobs.
+func (i *ImageService) loadDockerSave(ctx context.Context, blobs map[string]ocispec.Descriptor, manifestJSON []byte) ([]image.ID, error) {
+	var entries []dockerSaveManifestEntry
+	if err := json.Unmarshal(manifestJSON, &entries); err != nil {
+		return nil, errdefs.InvalidParameter(errors.Wrap(err, "parsing manifest.json"))
+	}
+
+	var ids []image.ID
+	for _, entry := range entries {
+		configBlobDesc, ok := blobs[path.Clean(entry.Config)]
+		if !ok {
+			return nil, errdefs.InvalidParameter(errors.Errorf("manifest.json references missing config %s", entry.Config))
+		}
+		configData, err := content.ReadBlob(ctx, i.content, configBlobDesc)
+		if err != nil {
+			return nil, errdefs.System(errors.Wrapf(err, "reading config %s", entry.Config))
+		}
+
+		var config imagespec.DockerOCIImage
+		if err := json.Unmarshal(configData, &config); err != nil {
+			return nil, errdefs.InvalidParameter(errors.Wrapf(err, "parsing config %s", entry.Config))
+		}
+		configDesc := ocispec.Descriptor{
+			MediaType: ocispec.MediaTypeImageConfig,
+			Digest:    configBlobDesc.Digest,
+			Size:      configBlobDesc.Size,
+		}
+
+		// The archive's layer order matches config.RootFS.DiffIDs; trust
+		// the DiffIDs the config already records rather than recomputing
+		// them, which would mean decompressing every layer again.
+		if len(entry.Layers) != len(config.RootFS.DiffIDs) {
+			return nil, errdefs.InvalidParameter(errors.Errorf("config %s has %d DiffIDs but manifest.json lists %d layers", entry.Config, len(config.RootFS.DiffIDs), len(entry.Layers)))
+		}
+
+		layers := make([]ocispec.Descriptor, len(entry.Layers))
+		gcLabels := map[string]string{"containerd.io/gc.ref.content.config": configDesc.Digest.String()}
+		for idx, layerPath := range entry.Layers {
+			desc, ok := blobs[path.Clean(layerPath)]
+			if !ok {
+				return nil, errdefs.InvalidParameter(errors.Errorf("manifest.json references missing layer %s", layerPath))
+			}
+			layers[idx] = desc
+			gcLabels[fmt.Sprintf("containerd.io/gc.ref.content.l.%d", idx)] = desc.Digest.String()
+		}
+
+		manifest := ocispec.Manifest{
+			MediaType: ocispec.MediaTypeImageManifest,
+			Versioned: specs.Versioned{
+				SchemaVersion: 2,
+			},
+			Config: configDesc,
+			Layers: layers,
+		}
+		manifestDesc, err := storeJson(ctx, i.content, ocispec.MediaTypeImageManifest, manifest, gcLabels)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := i.createAndUnpackLoadedImage(ctx, manifestDesc, normalizeLoadedRepoTags(entry.RepoTags))
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// loadOCILayout recreates every image an OCI image-layout's index.json
+// describes. A top-level manifest whose media type is itself an index
+// (a multi-platform image) is expanded one level; nested indexes beyond
+// that aren't supported, matching the OCI image-layout spec's own
+// "index of indexes" being optional/uncommon.
+func (i *ImageService) loadOCILayout(ctx context.Context, blobs map[string]ocispec.Descriptor, indexJSON []byte) ([]image.ID, error) {
+	var idx ocispec.Index
+	if err := json.Unmarshal(indexJSON, &idx); err != nil {
+		return nil, errdefs.InvalidParameter(errors.Wrap(err, "parsing index.json"))
+	}
+
+	var manifests []ocispec.Descriptor
+	for _, m := range idx.Manifests {
+		if m.MediaType != ocispec.MediaTypeImageIndex {
+			manifests = append(manifests, m)
+			continue
+		}
+		nested, err := ociBlobDesc(blobs, m.Digest)
+		if err != nil {
+			return nil, err
+		}
+		nestedData, err := content.ReadBlob(ctx, i.content, nested)
+		if err != nil {
+			return nil, errdefs.System(err)
+		}
+		var nestedIdx ocispec.Index
+		if err := json.Unmarshal(nestedData, &nestedIdx); err != nil {
+			return nil, errdefs.InvalidParameter(err)
+		}
+		manifests = append(manifests, nestedIdx.Manifests...)
+	}
+
+	var ids []image.ID
+	for _, m := range manifests {
+		desc, err := ociBlobDesc(blobs, m.Digest)
+		if err != nil {
+			return nil, err
+		}
+		// The manifest blob is already content-addressed under its own
+		// digest in blobs/<algo>/<digest>; reuse it directly rather than
+		// re-storing it.
+		manifestDesc := desc
+		manifestDesc.MediaType = m.MediaType
+		manifestDesc.Platform = m.Platform
+
+		var names []string
+		if name := m.Annotations[ocispec.AnnotationRefName]; name != "" {
+			names = []string{name}
+		}
+		id, err := i.createAndUnpackLoadedImage(ctx, manifestDesc, names)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// createAndUnpackLoadedImage creates one c8dimages.Image per name (or a
+// single dangling-named image if names is empty) pointing at
+// manifestDesc, and unpacks manifestDesc's snapshot once. It mirrors
+// ImportImage's own create-then-unpack-then-log sequence, including
+// propagating an unpack failure rather than reporting the load as
+// successful for an image that was never actually unpacked.
+func (i *ImageService) createAndUnpackLoadedImage(ctx context.Context, manifestDesc ocispec.Descriptor, names []string) (image.ID, error) {
+	id := image.ID(manifestDesc.Digest.String())
+	if len(names) == 0 {
+		names = []string{danglingImageName(manifestDesc.Digest)}
+	}
+
+	var unpacked bool
+	for _, name := range names {
+		img := c8dimages.Image{
+			Name:      name,
+			Target:    manifestDesc,
+			CreatedAt: time.Now(),
+		}
+		if err := i.createOrReplaceImage(ctx, img); err != nil {
+			return "", errdefs.System(errors.Wrapf(err, "saving image %s", name))
+		}
+		clearPullLeaseExpiration(ctx, i.client.LeasesService(), manifestDesc.Digest)
+		if !unpacked {
+			if err := i.unpackImage(ctx, i.StorageDriver(), img, manifestDesc, CryptoConfig{}); err != nil {
+				log.G(ctx).WithError(err).WithField("image", name).Debug("failed to unpack loaded image")
+				return id, err
+			}
+			unpacked = true
+		}
+		i.LogImageEvent(ctx, id.String(), id.String(), events.ActionImport)
+	}
+	return id, nil
+}
+
+// ociBlobDesc looks up a digest's descriptor among the blobs an OCI
+// layout archive walk collected, keyed by its conventional
+// "blobs/<algo>/<digest>" path.
+func ociBlobDesc(blobs map[string]ocispec.Descriptor, dgst digest.Digest) (ocispec.Descriptor, error) {
+	key := path.Join("blobs", dgst.Algorithm().String(), dgst.Encoded())
+	desc, ok := blobs[key]
+	if !ok {
+		return ocispec.Descriptor{}, errdefs.InvalidParameter(errors.Errorf("index.json references missing blob %s", dgst))
+	}
+	return desc, nil
+}
+
+// normalizeLoadedRepoTags parses each of a manifest.json entry's
+// RepoTags into a fully-qualified reference, skipping (rather than
+// failing the whole load on) any tag that doesn't parse.
+func normalizeLoadedRepoTags(tags []string) []string {
+	var out []string
+	for _, t := range tags {
+		ref, err := reference.ParseNormalizedNamed(t)
+		if err != nil {
+			continue
+		}
+		if tagged, ok := ref.(reference.NamedTagged); ok {
+			out = append(out, tagged.String())
+		} else {
+			out = append(out, reference.TagNameOnly(ref).String())
+		}
+	}
+	return out
+}
@@ -0,0 +1,361 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// copyMode selects how Driver.create copies a parent layer's directory
+// tree into a new layer. Create is the reason vfs is considered
+// impractical for real workloads: without one of the faster modes
+// below, it deep-copies every byte of the parent on every layer.
+type copyMode int
+
+const (
+	// copyModeAuto probes the destination filesystem and picks reflink
+	// if it works there and a full copy otherwise; it never picks
+	// hardlink. It's the default.
+	copyModeAuto copyMode = iota
+	// copyModeReflink clones each regular file with the FICLONE ioctl,
+	// falling back to copy_file_range (still copy-on-write capable on
+	// filesystems that support it) per file if cloning fails. Supported
+	// on XFS, Btrfs and bcachefs.
+	copyModeReflink
+	// copyModeHardlink hardlinks each regular file instead of copying
+	// its contents: orders of magnitude cheaper than a full copy, at
+	// the cost that the parent and child layer share inodes until
+	// something breaks the link. vfs has no copy-on-write of its own,
+	// so this mode is only safe for callers that never write to a
+	// layer through anything but the graphdriver's own APIs -- a
+	// container writing directly into its mounted upper layer corrupts
+	// every layer sharing that inode. Because that's true of ordinary
+	// container usage, this mode is never auto-detected; it only
+	// applies when a caller sets vfs.copymode=hardlink explicitly,
+	// accepting that risk themselves.
+	copyModeHardlink
+	// copyModeFull deep-copies every file's contents: the original vfs
+	// behavior, and the only mode guaranteed to work everywhere.
+	copyModeFull
+)
+
+const copyModeStorageOpt = "vfs.copymode"
+
+func parseCopyMode(val string) (copyMode, error) {
+	switch val {
+	case "reflink":
+		return copyModeReflink, nil
+	case "hardlink":
+		return copyModeHardlink, nil
+	case "full":
+		return copyModeFull, nil
+	default:
+		return copyModeAuto, errors.Errorf("unknown value %q for %s, must be one of reflink, hardlink, full", val, copyModeStorageOpt)
+	}
+}
+
+// CopyDir defines the fallback, full-content-copy method, kept as a
+// package variable so tests can swap it out the same way they always
+// could before copy modes existed.
+var CopyDir = copyTreeFull
+
+// copyDir copies parentDir's tree into dir, honoring d's configured
+// copy mode (autodetecting one if unset), and falling back to a slower
+// mode if the faster one fails partway through. A reflink failure
+// falls back straight to a full copy, never to hardlink: hardlink
+// sharing inodes between layers is only acceptable when the caller
+// asked for it explicitly via vfs.copymode=hardlink, never as a
+// fallback picked on its behalf.
+func (d *Driver) copyDir(parentDir, dir string) error {
+	mode := d.copyMode
+	if mode == copyModeAuto {
+		mode = detectCopyMode(dir)
+	}
+
+	if mode == copyModeReflink {
+		if err := copyTreeReflink(parentDir, dir); err == nil {
+			return nil
+		}
+		if err := clearDir(dir); err != nil {
+			return err
+		}
+		mode = copyModeFull
+	}
+	if mode == copyModeHardlink {
+		if err := copyTreeHardlink(parentDir, dir); err == nil {
+			return nil
+		}
+		if err := clearDir(dir); err != nil {
+			return err
+		}
+		mode = copyModeFull
+	}
+	return CopyDir(parentDir, dir)
+}
+
+// detectCopyMode probes dir's filesystem for reflink support by
+// cloning a throwaway file. copyModeHardlink is deliberately never
+// auto-detected: vfs has no copy-on-write of its own, so sharing
+// inodes between layers is only safe when a caller explicitly opts
+// into it via vfs.copymode=hardlink, never as a silent default. A
+// failed reflink probe falls back to a full copy instead.
+func detectCopyMode(dir string) copyMode {
+	if reflinkSupported(dir) {
+		return copyModeReflink
+	}
+	return copyModeFull
+}
+
+func reflinkSupported(dir string) bool {
+	src, err := os.CreateTemp(dir, ".vfs-reflink-probe-src")
+	if err != nil {
+		return false
+	}
+	defer os.Remove(src.Name())
+	defer src.Close()
+	if _, err := src.Write([]byte("x")); err != nil {
+		return false
+	}
+
+	dstPath := src.Name() + ".dst"
+	dst, err := os.Create(dstPath)
+	if err != nil {
+		return false
+	}
+	defer os.Remove(dstPath)
+	defer dst.Close()
+
+	return unix.IoctlFileClone(int(dst.Fd()), int(src.Fd())) == nil
+}
+
+// clearDir removes dir's contents (but not dir itself) so a fallback
+// copy mode starts from a clean slate rather than layering on top of a
+// partially-completed faster attempt.
+func clearDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.RemoveAll(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyTreeReflink(src, dst string) error {
+	return copyTree(src, dst, reflinkFile)
+}
+
+func copyTreeHardlink(src, dst string) error {
+	return copyTree(src, dst, hardlinkFile)
+}
+
+func copyTreeFull(src, dst string) error {
+	return copyTree(src, dst, plainCopyFile)
+}
+
+// permBits are the mode bits fchmodFull/os.Mkdir/os.OpenFile need in
+// order to reproduce a file exactly, beyond the plain rwx permission
+// bits: setuid, setgid and the sticky bit all affect how the copy
+// behaves once running (e.g. a setuid binary like /usr/bin/sudo), so
+// dropping them silently changes the semantics of every layer stacked
+// on top of this one.
+const permBits = os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+
+// statOwner returns info's on-disk uid/gid, as reported by the
+// platform-specific Stat_t embedded in info.Sys(). The copied tree is
+// reproduced with the exact ownership it already has in the parent
+// layer -- not remapped through the driver's idMapping -- because the
+// parent layer's files were themselves written with that mapping
+// already applied; copying verbatim is what preserves it.
+func statOwner(info fs.FileInfo) (uid, gid int) {
+	st := info.Sys().(*syscall.Stat_t)
+	return int(st.Uid), int(st.Gid)
+}
+
+// copyTree walks src and recreates every entry under dst: directories
+// are made fresh (so permissions/ownership can be set explicitly
+// instead of relying on umask), symlinks are recreated pointing at the
+// same target, regular files are reproduced with copyFile, and
+// device/socket/FIFO nodes are recreated with mknod. Every entry's
+// ownership and full mode (including setuid/setgid/sticky) are
+// preserved to match what the baseline `cp -aT` did.
+func copyTree(src, dst string, copyFile func(src, dst string, info fs.FileInfo) error) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if rel == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		uid, gid := statOwner(info)
+
+		switch {
+		case d.IsDir():
+			if err := os.Mkdir(target, info.Mode()&permBits); err != nil {
+				return err
+			}
+			if err := os.Lchown(target, uid, gid); err != nil {
+				return err
+			}
+		case d.Type()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(link, target); err != nil {
+				return err
+			}
+			return os.Lchown(target, uid, gid)
+		case info.Mode().IsRegular():
+			if err := copyFile(path, target, info); err != nil {
+				return err
+			}
+			if err := os.Lchown(target, uid, gid); err != nil {
+				return err
+			}
+		default:
+			return mknod(target, info, uid, gid)
+		}
+		// Set the mode last: os.Mkdir applies the umask, and chown clears
+		// setuid/setgid on regular files.
+		return os.Chmod(target, info.Mode()&permBits)
+	})
+}
+
+// rawMode converts m's permission and setuid/setgid/sticky bits to the
+// raw numeric mode unix.Mknod/unix.Mkfifo expect. Unlike os.Mkdir/
+// os.OpenFile/os.Chmod, which do this translation themselves for an
+// os.FileMode argument, the raw mknod(2) wrapper takes the bits as-is.
+func rawMode(m fs.FileMode) uint32 {
+	mode := uint32(m.Perm())
+	if m&os.ModeSetuid != 0 {
+		mode |= unix.S_ISUID
+	}
+	if m&os.ModeSetgid != 0 {
+		mode |= unix.S_ISGID
+	}
+	if m&os.ModeSticky != 0 {
+		mode |= unix.S_ISVTX
+	}
+	return mode
+}
+
+// mknod recreates a device, FIFO or socket special file, which plain
+// read/write/reflink copying can't reproduce.
+func mknod(target string, info fs.FileInfo, uid, gid int) error {
+	st := info.Sys().(*syscall.Stat_t)
+	mode := rawMode(info.Mode())
+	switch {
+	case info.Mode()&os.ModeDevice != 0:
+		if info.Mode()&os.ModeCharDevice != 0 {
+			mode |= unix.S_IFCHR
+		} else {
+			mode |= unix.S_IFBLK
+		}
+		if err := unix.Mknod(target, mode, int(st.Rdev)); err != nil {
+			return errors.Wrapf(err, "mknod %s", target)
+		}
+	case info.Mode()&os.ModeNamedPipe != 0:
+		if err := unix.Mkfifo(target, mode); err != nil {
+			return errors.Wrapf(err, "mkfifo %s", target)
+		}
+	case info.Mode()&os.ModeSocket != 0:
+		if err := unix.Mknod(target, mode|unix.S_IFSOCK, 0); err != nil {
+			return errors.Wrapf(err, "mknod %s", target)
+		}
+	default:
+		// Not a type Create is expected to see in an image layer
+		// (e.g. a door or whiteout outside the usual overlay
+		// convention); nothing sane to recreate it as.
+		return nil
+	}
+	if err := os.Lchown(target, uid, gid); err != nil {
+		return err
+	}
+	// mknod(2) applies the umask, so set the mode explicitly.
+	return os.Chmod(target, info.Mode()&permBits)
+}
+
+func plainCopyFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode()&permBits)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return err
+	}
+	return out.Chmod(info.Mode() & permBits)
+}
+
+// reflinkFile clones src onto dst with FICLONE; if that's not
+// supported by the destination filesystem (EOPNOTSUPP, EXDEV, EINVAL),
+// it falls back to copy_file_range, which is still copy-on-write where
+// the filesystem supports it, and to a plain read/write copy otherwise.
+func reflinkFile(src, dst string, info fs.FileInfo) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode()&permBits)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := unix.IoctlFileClone(int(out.Fd()), int(in.Fd())); err == nil {
+		return out.Chmod(info.Mode() & permBits)
+	}
+
+	size := info.Size()
+	for size > 0 {
+		n, err := unix.CopyFileRange(int(in.Fd()), nil, int(out.Fd()), nil, int(size), 0)
+		if err != nil {
+			return plainCopyFile(src, dst, info)
+		}
+		if n == 0 {
+			break
+		}
+		size -= int64(n)
+	}
+	return out.Chmod(info.Mode() & permBits)
+}
+
+// hardlinkFile links dst to src instead of copying its contents. If
+// src and dst are on different filesystems (EXDEV), it falls back to a
+// reflink attempt, then a plain copy. A successful link shares src's
+// inode, so its mode/ownership already match and don't need to be set
+// again.
+func hardlinkFile(src, dst string, info fs.FileInfo) error {
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+	return reflinkFile(src, dst, info)
+}
@@ -0,0 +1,91 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestParseCopyMode(t *testing.T) {
+	for _, tc := range []struct {
+		val     string
+		want    copyMode
+		wantErr bool
+	}{
+		{val: "reflink", want: copyModeReflink},
+		{val: "hardlink", want: copyModeHardlink},
+		{val: "full", want: copyModeFull},
+		{val: "bogus", wantErr: true},
+	} {
+		got, err := parseCopyMode(tc.val)
+		if tc.wantErr {
+			assert.Check(t, err != nil)
+			continue
+		}
+		assert.NilError(t, err)
+		assert.Check(t, got == tc.want)
+	}
+}
+
+func TestCopyTreeFullReproducesTree(t *testing.T) {
+	src := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(src, "file"), []byte("hello"), 0o644))
+	assert.NilError(t, os.Mkdir(filepath.Join(src, "sub"), 0o755))
+	assert.NilError(t, os.WriteFile(filepath.Join(src, "sub", "nested"), []byte("world"), 0o644))
+	assert.NilError(t, os.Symlink("file", filepath.Join(src, "link")))
+
+	dst := t.TempDir()
+	assert.NilError(t, copyTreeFull(src, dst))
+
+	got, err := os.ReadFile(filepath.Join(dst, "file"))
+	assert.NilError(t, err)
+	assert.Check(t, string(got) == "hello")
+
+	got, err = os.ReadFile(filepath.Join(dst, "sub", "nested"))
+	assert.NilError(t, err)
+	assert.Check(t, string(got) == "world")
+
+	target, err := os.Readlink(filepath.Join(dst, "link"))
+	assert.NilError(t, err)
+	assert.Check(t, target == "file")
+}
+
+func TestCopyTreeFullPreservesSetuidAndOwnership(t *testing.T) {
+	src := t.TempDir()
+	file := filepath.Join(src, "setuid-bin")
+	assert.NilError(t, os.WriteFile(file, []byte("bin"), 0o755))
+	assert.NilError(t, os.Chmod(file, 0o4755))
+
+	srcInfo, err := os.Stat(file)
+	assert.NilError(t, err)
+	srcOwner := srcInfo.Sys().(*syscall.Stat_t)
+
+	dst := t.TempDir()
+	assert.NilError(t, copyTreeFull(src, dst))
+
+	dstInfo, err := os.Stat(filepath.Join(dst, "setuid-bin"))
+	assert.NilError(t, err)
+	assert.Check(t, dstInfo.Mode()&os.ModeSetuid != 0, "setuid bit was stripped by the copy")
+	assert.Check(t, dstInfo.Mode().Perm() == 0o755)
+
+	dstOwner := dstInfo.Sys().(*syscall.Stat_t)
+	assert.Check(t, dstOwner.Uid == srcOwner.Uid)
+	assert.Check(t, dstOwner.Gid == srcOwner.Gid)
+}
+
+func TestCopyTreeHardlinkSharesInode(t *testing.T) {
+	src := t.TempDir()
+	assert.NilError(t, os.WriteFile(filepath.Join(src, "file"), []byte("hello"), 0o644))
+
+	dst := t.TempDir()
+	assert.NilError(t, copyTreeHardlink(src, dst))
+
+	srcInfo, err := os.Stat(filepath.Join(src, "file"))
+	assert.NilError(t, err)
+	dstInfo, err := os.Stat(filepath.Join(dst, "file"))
+	assert.NilError(t, err)
+	assert.Check(t, os.SameFile(srcInfo, dstInfo))
+}
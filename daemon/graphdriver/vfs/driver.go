@@ -20,9 +20,6 @@ const (
 	bestEffortXattrsOptValue = "i_want_broken_containers"
 )
 
-// CopyDir defines the copy method to use.
-var CopyDir = dirCopy
-
 func init() {
 	graphdriver.Register("vfs", Init)
 }
@@ -66,6 +63,7 @@ type Driver struct {
 	home             string
 	idMapping        user.IdentityMapping
 	bestEffortXattrs bool
+	copyMode         copyMode
 }
 
 func (d *Driver) String() string {
@@ -114,6 +112,12 @@ func (d *Driver) parseOptions(options []string) error {
 				return errdefs.InvalidParameter(errors.Errorf("do not set the " + xattrsStorageOpt + " option unless you are willing to accept the consequences"))
 			}
 			d.bestEffortXattrs = true
+		case copyModeStorageOpt:
+			mode, err := parseCopyMode(val)
+			if err != nil {
+				return errdefs.InvalidParameter(err)
+			}
+			d.copyMode = mode
 		default:
 			return errdefs.InvalidParameter(errors.Errorf("unknown option %s for vfs", key))
 		}
@@ -184,7 +188,7 @@ func (d *Driver) create(id, parent string, size uint64) error {
 	if err != nil {
 		return fmt.Errorf("%s: %s", parent, err)
 	}
-	return CopyDir(parentDir, dir)
+	return d.copyDir(parentDir, dir)
 }
 
 func (d *Driver) dir(id string) string {
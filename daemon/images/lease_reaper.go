@@ -0,0 +1,155 @@
+package images
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/containerd/containerd/v2/core/content"
+	"github.com/containerd/containerd/v2/core/leases"
+	"github.com/containerd/log"
+	"github.com/moby/moby/v2/daemon/internal/image"
+	"github.com/opencontainers/go-digest"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultReapInterval is how often the reaper scans for orphaned pull
+// leases.
+const defaultReapInterval = 15 * time.Minute
+
+var (
+	leasesReapedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "moby",
+		Subsystem: "images",
+		Name:      "pull_leases_reaped_total",
+		Help:      "Number of per-pull leases deleted by the orphaned-lease reaper.",
+	})
+	orphanedBytesFreedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "moby",
+		Subsystem: "images",
+		Name:      "pull_lease_orphaned_bytes_freed_total",
+		Help:      "Total size, in bytes, of content released by the orphaned-lease reaper.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(leasesReapedTotal, orphanedBytesFreedTotal)
+}
+
+// leaseReaper periodically deletes per-pull leases (see
+// imageStoreForPull.updateLease) whose image was never committed,
+// because the daemon crashed or the client disconnected mid-pull, so the
+// content they were pinning doesn't outlive the pull forever.
+type leaseReaper struct {
+	leases   leases.Manager
+	content  content.Manager
+	images   image.Store
+	interval time.Duration
+}
+
+// newLeaseReaper returns a leaseReaper that scans every interval (or
+// defaultReapInterval, if interval is zero).
+func newLeaseReaper(lm leases.Manager, cs content.Manager, imgs image.Store, interval time.Duration) *leaseReaper {
+	if interval <= 0 {
+		interval = defaultReapInterval
+	}
+	return &leaseReaper{leases: lm, content: cs, images: imgs, interval: interval}
+}
+
+// Run scans for orphaned pull leases every r.interval until ctx is done.
+func (r *leaseReaper) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reapOnce(ctx); err != nil {
+				log.G(ctx).WithError(err).Warn("images: orphaned pull lease scan failed")
+			}
+		}
+	}
+}
+
+// reapOnce deletes every moby-image- lease whose image ID no longer
+// exists in the image store and whose gc.expire label (see
+// imageStoreForPull.updateLease/refreshLeaseExpiration) has passed. A
+// pull still within its TTL is left alone even though its image isn't
+// committed yet: that's the normal state of any pull still in
+// progress when the ticker fires, not an orphan.
+func (r *leaseReaper) reapOnce(ctx context.Context) error {
+	all, err := r.leases.List(ctx)
+	if err != nil {
+		return errors.Wrap(err, "listing leases")
+	}
+
+	for _, l := range all {
+		dgst, ok := strings.CutPrefix(l.ID, imageKeyPrefix)
+		if !ok {
+			continue
+		}
+		if _, err := r.images.Get(image.ID(digest.Digest(dgst))); err == nil {
+			continue // the pull committed; the image store itself now owns this content.
+		}
+		if !r.expired(ctx, l) {
+			continue // still within its TTL; likely just a pull still in progress.
+		}
+
+		freed := r.orphanedSize(ctx, l)
+
+		if err := r.leases.Delete(ctx, l); err != nil {
+			log.G(ctx).WithError(err).WithField("lease", l.ID).Warn("images: failed to delete orphaned pull lease")
+			continue
+		}
+
+		leasesReapedTotal.Inc()
+		orphanedBytesFreedTotal.Add(float64(freed))
+		log.G(ctx).WithFields(log.Fields{"lease": l.ID, "bytes": freed}).Info("images: reaped orphaned pull lease")
+	}
+	return nil
+}
+
+// expired reports whether l's gc.expire label (see
+// imageStoreForPull.refreshLeaseExpiration) names a time that has
+// already passed. A lease with no parseable gc.expire label is
+// treated as expired, since updateLease always sets one at creation
+// and there's otherwise no TTL to honor.
+func (r *leaseReaper) expired(ctx context.Context, l leases.Lease) bool {
+	v, ok := l.Labels[gcExpireLabel]
+	if !ok {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		log.G(ctx).WithError(err).WithField("lease", l.ID).Warn("images: failed to parse lease expiration; treating as expired")
+		return true
+	}
+	return t.Before(time.Now())
+}
+
+// orphanedSize sums the size of the content resources pinned by l, for
+// the orphaned-bytes-freed metric. Errors sizing an individual resource
+// are logged and otherwise ignored: they shouldn't block reaping the
+// lease itself.
+func (r *leaseReaper) orphanedSize(ctx context.Context, l leases.Lease) int64 {
+	resources, err := r.leases.ListResources(ctx, l)
+	if err != nil {
+		log.G(ctx).WithError(err).WithField("lease", l.ID).Warn("images: failed to list resources for orphaned lease")
+		return 0
+	}
+
+	var total int64
+	for _, res := range resources {
+		if res.Type != "content" {
+			continue
+		}
+		info, err := r.content.Info(ctx, digest.Digest(res.ID))
+		if err != nil {
+			continue
+		}
+		total += info.Size
+	}
+	return total
+}
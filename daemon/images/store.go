@@ -3,6 +3,7 @@ package images
 import (
 	"context"
 	"sync"
+	"time"
 
 	"github.com/containerd/containerd/v2/core/content"
 	"github.com/containerd/containerd/v2/core/leases"
@@ -18,6 +19,20 @@ import (
 
 const imageKeyPrefix = "moby-image-"
 
+// defaultPullLeaseTTL is how long a per-pull lease (see imageStoreForPull)
+// is allowed to outlive its pull before the background reaper considers it
+// orphaned. It is refreshed on every successful Put and cleared once the
+// image is committed, so a healthy, ongoing pull never hits it; it only
+// matters if the daemon crashes or the client disconnects mid-pull.
+const defaultPullLeaseTTL = 24 * time.Hour
+
+// gcExpireLabel is the lease label containerd's garbage collector reads
+// to decide when an otherwise-unreferenced lease may be collected. It is
+// set directly (rather than only through leases.WithExpiration, which
+// only applies at creation) so updateLease can refresh or clear it on an
+// existing lease.
+const gcExpireLabel = "containerd.io/gc.expire"
+
 func imageKey(dgst string) string {
 	return imageKeyPrefix + dgst
 }
@@ -49,6 +64,11 @@ type imageStoreForPull struct {
 	distribution.ImageConfigStore
 	leases   leases.Manager
 	ingested *contentStoreForPull
+
+	// leaseTTL bounds how long the pull's lease may live without being
+	// refreshed by a successful Put before the reaper treats it as
+	// orphaned. Zero means defaultPullLeaseTTL.
+	leaseTTL time.Duration
 }
 
 func (s *imageStoreForPull) Put(ctx context.Context, config []byte) (digest.Digest, error) {
@@ -69,12 +89,19 @@ func (s *imageStoreForPull) Get(ctx context.Context, dgst digest.Digest) ([]byte
 
 func (s *imageStoreForPull) updateLease(ctx context.Context, dgst digest.Digest) error {
 	leaseID := imageKey(dgst.String())
-	lease, err := s.leases.Create(ctx, leases.WithID(leaseID))
+	ttl := s.leaseTTL
+	if ttl <= 0 {
+		ttl = defaultPullLeaseTTL
+	}
+	lease, err := s.leases.Create(ctx, leases.WithID(leaseID), leases.WithExpiration(ttl))
 	if err != nil {
 		if !cerrdefs.IsAlreadyExists(err) {
 			return errors.Wrap(err, "error creating lease")
 		}
 		lease = leases.Lease{ID: leaseID}
+		if err := s.refreshLeaseExpiration(ctx, lease, ttl); err != nil {
+			return err
+		}
 	}
 
 	digested := s.ingested.getDigested()
@@ -95,6 +122,41 @@ func (s *imageStoreForPull) updateLease(ctx context.Context, dgst digest.Digest)
 	return nil
 }
 
+// refreshLeaseExpiration bumps lease's gc.expire label to ttl from now,
+// called on every successful Put so a pull that's still making progress
+// never has its lease reaped out from under it.
+func (s *imageStoreForPull) refreshLeaseExpiration(ctx context.Context, lease leases.Lease, ttl time.Duration) error {
+	lease.Labels = map[string]string{gcExpireLabel: time.Now().Add(ttl).UTC().Format(time.RFC3339)}
+	if err := s.leases.Update(ctx, lease, "labels"); err != nil {
+		return errors.Wrap(err, "error refreshing lease expiration")
+	}
+	return nil
+}
+
+// CommitComplete clears dgst's pull lease expiration, called once the
+// image has been fully committed to the image store. A committed image
+// is referenced by the image store itself from then on, so the lease no
+// longer needs a TTL safety net.
+func (s *imageStoreForPull) CommitComplete(ctx context.Context, dgst digest.Digest) error {
+	return ClearPullLeaseExpiration(ctx, s.leases, dgst)
+}
+
+// ClearPullLeaseExpiration clears the gc.expire label on dgst's
+// moby-image- lease, the same way imageStoreForPull.CommitComplete
+// does for a pull running through imageStoreForPull itself. It's
+// exported so a caller that commits an image some other way (e.g. the
+// containerd-backed ImageService's ImportImage/LoadImage, which create
+// their own lease via leases.WithExpiration rather than going through
+// imageStoreForPull) can still tell the reaper the image is safely
+// committed and no longer needs its TTL safety net.
+func ClearPullLeaseExpiration(ctx context.Context, lm leases.Manager, dgst digest.Digest) error {
+	lease := leases.Lease{ID: imageKey(dgst.String()), Labels: map[string]string{}}
+	if err := lm.Update(ctx, lease, "labels"); err != nil {
+		return errors.Wrap(err, "error clearing lease expiration")
+	}
+	return nil
+}
+
 // contentStoreForPull is used to wrap the configured content store to
 // add lease management for a single `pull`
 // It stores all committed digests so that `imageStoreForPull` can add
@@ -107,10 +169,20 @@ type contentStoreForPull struct {
 	digested []digest.Digest
 }
 
+// addDigested records dgst as ingested by this pull, ignoring a digest
+// already recorded: the writer for a descriptor whose digest is known
+// up front is registered twice (once eagerly when the writer is opened,
+// once again on a successful Commit), and without deduping here that
+// would add the same resource to the lease twice.
 func (c *contentStoreForPull) addDigested(dgst digest.Digest) {
 	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.digested {
+		if d == dgst {
+			return
+		}
+	}
 	c.digested = append(c.digested, dgst)
-	c.mu.Unlock()
 }
 
 func (c *contentStoreForPull) getDigested() []digest.Digest {
@@ -122,19 +194,29 @@ func (c *contentStoreForPull) getDigested() []digest.Digest {
 }
 
 func (c *contentStoreForPull) Writer(ctx context.Context, opts ...content.WriterOpt) (content.Writer, error) {
+	var cfg content.WriterOpts
+	for _, o := range opts {
+		if err := o(&cfg); err != nil {
+			return nil, err
+		}
+	}
+
 	w, err := c.ContentStore.Writer(ctx, opts...)
 	if err != nil {
 		if cerrdefs.IsAlreadyExists(err) {
-			var cfg content.WriterOpts
-			for _, o := range opts {
-				if err := o(&cfg); err != nil {
-					return nil, err
-				}
-			}
 			c.addDigested(cfg.Desc.Digest)
 		}
 		return nil, err
 	}
+
+	// Register the descriptor with the pull's lease as soon as the
+	// writer exists, not only once Commit succeeds: this ensures a
+	// crash mid-download doesn't leave the partial ingest outside the
+	// lease's GC roots.
+	if cfg.Desc.Digest != "" {
+		c.addDigested(cfg.Desc.Digest)
+	}
+
 	return &contentWriter{
 		cs:     c,
 		Writer: w,
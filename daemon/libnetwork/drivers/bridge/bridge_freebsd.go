@@ -0,0 +1,166 @@
+//go:build freebsd
+
+// Package bridge implements a FreeBSD bridge network driver backed by
+// if_bridge(4) and epair(4), with jail(2) as the sandbox primitive. It
+// mirrors the role of the Linux bridge driver, but substitutes FreeBSD's
+// native building blocks for veth/netns:
+//
+//   - if_bridge(4) in place of the Linux bridge device
+//   - epair(4) interface pairs in place of veth pairs
+//   - jail(2)/jail_attach(2) in place of network namespaces
+//   - routing-socket (PF_ROUTE) calls in place of netlink
+//
+// This file only wires up driver registration and the network/endpoint
+// bookkeeping; the jail and epair plumbing lives in freebsd-only files
+// under libnetwork/osl, libnetwork/ns, and libnetwork/iptables (pf/ipfw)
+// that are added alongside it as that support lands.
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/moby/moby/v2/daemon/libnetwork/driverapi"
+	"github.com/moby/moby/v2/daemon/libnetwork/scope"
+	"github.com/moby/moby/v2/daemon/libnetwork/types"
+)
+
+// NetworkType is the name FreeBSD registers its bridge driver under. It is
+// intentionally the same name Linux uses ("bridge"), so that
+// `docker network create` and the default network behave identically
+// regardless of host OS.
+const NetworkType = "bridge"
+
+// driver implements driverapi.Driver on top of if_bridge(4)/epair(4)/jail(2).
+type driver struct {
+	mu       sync.Mutex
+	networks map[string]*bridgeNetwork
+}
+
+// bridgeNetwork tracks the if_bridge(4) device and epair(4) pairs backing
+// a single Docker network.
+type bridgeNetwork struct {
+	id        string
+	bridgeIfc string // if_bridge(4) interface name, e.g. "bridge0"
+	endpoints map[string]*bridgeEndpoint
+}
+
+// bridgeEndpoint tracks one epair(4) pair: the host-side half stays
+// attached to the if_bridge(4), the jail-side half is handed to the
+// sandbox (jail) on Join.
+type bridgeEndpoint struct {
+	id      string
+	hostIfc string // e.g. "epair0a"
+	jailIfc string // e.g. "epair0b"
+}
+
+// Register registers the FreeBSD bridge driver with reg, matching the
+// registration pattern used by other libnetwork drivers (see
+// driverapi.Registerer and its use in the Linux bridge driver).
+func Register(reg driverapi.Registerer) error {
+	d := &driver{networks: map[string]*bridgeNetwork{}}
+	return reg.RegisterDriver(NetworkType, d, driverapi.Capability{DataScope: scope.Local})
+}
+
+func (d *driver) CreateNetwork(ctx context.Context, nid string, options map[string]interface{}, nInfo driverapi.NetworkInfo, ipV4Data, ipV6Data []driverapi.IPAMData) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.networks[nid]; ok {
+		return fmt.Errorf("network %s already exists", nid)
+	}
+	// TODO(freebsd): allocate an if_bridge(4) device (e.g. via
+	// `ifconfig bridge create`) and assign the IPAM gateway(s) from
+	// ipV4Data/ipV6Data to it.
+	d.networks[nid] = &bridgeNetwork{id: nid, endpoints: map[string]*bridgeEndpoint{}}
+	return nil
+}
+
+func (d *driver) DeleteNetwork(nid string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	nw, ok := d.networks[nid]
+	if !ok {
+		return types.NotFoundErrorf("network %s not found", nid)
+	}
+	if len(nw.endpoints) != 0 {
+		return fmt.Errorf("network %s has active endpoints", nid)
+	}
+	// TODO(freebsd): destroy the if_bridge(4) device.
+	delete(d.networks, nid)
+	return nil
+}
+
+func (d *driver) CreateEndpoint(_ context.Context, nid, eid string, ifInfo driverapi.InterfaceInfo, options map[string]interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	nw, ok := d.networks[nid]
+	if !ok {
+		return types.NotFoundErrorf("network %s not found", nid)
+	}
+	if _, ok := nw.endpoints[eid]; ok {
+		return fmt.Errorf("endpoint %s already exists", eid)
+	}
+	// TODO(freebsd): create an epair(4) pair (`ifconfig epair create`),
+	// add the host-side half to the network's if_bridge(4), and set
+	// ifInfo's MAC/address from the host-side half.
+	nw.endpoints[eid] = &bridgeEndpoint{id: eid}
+	return nil
+}
+
+func (d *driver) DeleteEndpoint(nid, eid string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	nw, ok := d.networks[nid]
+	if !ok {
+		return types.NotFoundErrorf("network %s not found", nid)
+	}
+	// TODO(freebsd): remove the host-side epair(4) half from the
+	// if_bridge(4) and destroy the pair.
+	delete(nw.endpoints, eid)
+	return nil
+}
+
+func (d *driver) EndpointOperInfo(nid, eid string) (map[string]interface{}, error) {
+	return nil, nil
+}
+
+func (d *driver) Join(_ context.Context, nid, eid string, sboxKey string, jinfo driverapi.JoinInfo, _, _ map[string]interface{}) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	nw, ok := d.networks[nid]
+	if !ok {
+		return types.NotFoundErrorf("network %s not found", nid)
+	}
+	if _, ok := nw.endpoints[eid]; !ok {
+		return types.NotFoundErrorf("endpoint %s not found", eid)
+	}
+	// TODO(freebsd): jail_attach(2) the sandbox jail identified by
+	// sboxKey, move the jail-side epair(4) half into it, and translate
+	// jinfo (gateway, static routes) into jail network parameters.
+	return types.NotImplementedErrorf("jail-based join is not yet implemented")
+}
+
+func (d *driver) Leave(nid, eid string) error {
+	return nil
+}
+
+func (d *driver) Type() string {
+	return NetworkType
+}
+
+// IsBuiltIn reports false: Join, NetworkAllocate, and NetworkFree only
+// return NotImplemented errors, so this driver isn't ready to be
+// treated as a trusted, always-available built-in the way the Linux
+// bridge driver is. Flip this once jail/epair support lands.
+func (d *driver) IsBuiltIn() bool {
+	return false
+}
+
+func (d *driver) NetworkAllocate(id string, option map[string]string, ipV4Data, ipV6Data []driverapi.IPAMData) (map[string]string, error) {
+	return nil, types.NotImplementedErrorf("not implemented")
+}
+
+func (d *driver) NetworkFree(id string) error {
+	return types.NotImplementedErrorf("not implemented")
+}
@@ -81,6 +81,46 @@ func setKey(ctx context.Context) error {
 	return setExternalKey(ctx, shortCtlrID, containerID, fmt.Sprintf("/proc/%d/ns/net", state.Pid), *execRoot)
 }
 
+// ExternalKeyHook builds an OCI createRuntime hook that drives external
+// key assignment for containerID the same way processSetKeyReexec
+// already does, for runtimes that support the standard hook contract
+// (runc, crun, kata, youki, ...) rather than relying on the daemon
+// itself re-exec'ing argv[0]="libnetwork-setkey". The caller (assembling
+// a container's runtime spec) decides via useHook whether hook mode is
+// configured; ExternalKeyHook itself reports ok=false (not an error)
+// when useHook is false, so a caller can pass its own opt-in state
+// straight through and just skip appending a hook if ok is false.
+//
+// The hook runs the exact same processSetKeyReexec entrypoint as the
+// re-exec path: it reads specs.State from stdin, resolves
+// /proc/<pid>/ns/net from it, and calls through to the existing
+// external-key UDS server via setExternalKey. Only how the runtime
+// invokes that entrypoint changes; startExternalKeyListener's server
+// side is unchanged and kept running regardless of hook mode, so
+// existing re-exec-based runtimes keep working without reconfiguration.
+func (c *Controller) ExternalKeyHook(containerID string, useHook bool) (hook specs.Hook, ok bool, err error) {
+	if !useHook {
+		return specs.Hook{}, false, nil
+	}
+
+	execRoot := defaultExecRoot
+	if v := c.Config().ExecRoot; v != "" {
+		execRoot = v
+	}
+
+	selfPath, err := os.Executable()
+	if err != nil {
+		return specs.Hook{}, false, fmt.Errorf("resolving daemon binary for external key hook: %w", err)
+	}
+
+	shortCtlrID := stringid.TruncateID(c.id)
+	return specs.Hook{
+		Path: selfPath,
+		Args: []string{"libnetwork-setkey", "-exec-root", execRoot, containerID, shortCtlrID},
+		Env:  os.Environ(),
+	}, true, nil
+}
+
 // setExternalKey provides a convenient way to set an External key to a sandbox
 func setExternalKey(ctx context.Context, shortCtlrID string, containerID string, key string, execRoot string) error {
 	uds := filepath.Join(execRoot, execSubdir, shortCtlrID+".sock")
@@ -0,0 +1,66 @@
+// Package ansi strips ANSI/VT100 terminal control sequences from log
+// output, so that API consumers that don't run a terminal emulator (log
+// shippers, dashboards, `docker logs` piped to a file) get clean text.
+package ansi
+
+import "strings"
+
+const (
+	esc = 0x1b
+	bel = 0x07
+)
+
+// Strip removes CSI and OSC escape sequences and collapses backspaces
+// (and the character they erase) from s, returning plain text.
+//
+// This mirrors the escape sequences a terminal emulator would otherwise
+// interpret: CSI sequences (ESC '[' ... final byte in 0x40-0x7e), OSC
+// sequences (ESC ']' ... terminated by BEL or ESC '\'), single-character
+// escapes (e.g. ESC 'c'), and cursor-move-then-backspace sequences used
+// to redraw progress output.
+func Strip(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\b':
+			// Backspace: drop it along with the previous printable rune,
+			// mirroring what a terminal would show after the erase.
+			if b.Len() > 0 {
+				out := []rune(b.String())
+				b.Reset()
+				b.WriteString(string(out[:len(out)-1]))
+			}
+		case r == esc && i+1 < len(runes) && runes[i+1] == '[':
+			// CSI sequence: ESC '[' <params/intermediates> <final byte>.
+			j := i + 2
+			for j < len(runes) && !(runes[j] >= 0x40 && runes[j] <= 0x7e) {
+				j++
+			}
+			i = j // skip the final byte too (loop's i++ lands past it)
+		case r == esc && i+1 < len(runes) && runes[i+1] == ']':
+			// OSC sequence: ESC ']' ... terminated by BEL or ESC '\' (ST).
+			j := i + 2
+			for j < len(runes) {
+				if runes[j] == bel {
+					break
+				}
+				if runes[j] == esc && j+1 < len(runes) && runes[j+1] == '\\' {
+					j++
+					break
+				}
+				j++
+			}
+			i = j
+		case r == esc && i+1 < len(runes):
+			// Single-character escape (e.g. ESC 'c' full reset).
+			i++
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,29 @@
+package ansi
+
+import (
+	"testing"
+
+	"gotest.tools/v3/assert"
+)
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		doc      string
+		in       string
+		expected string
+	}{
+		{doc: "plain text", in: "hello world", expected: "hello world"},
+		{doc: "CSI color sequence", in: "\x1b[31mred\x1b[0m", expected: "red"},
+		{doc: "CSI cursor move", in: "a\x1b[2Db", expected: "ab"},
+		{doc: "OSC title sequence terminated by BEL", in: "\x1b]0;title\aplain", expected: "plain"},
+		{doc: "OSC sequence terminated by ST", in: "\x1b]0;title\x1b\\plain", expected: "plain"},
+		{doc: "backspace collapses previous rune", in: "ab\bc", expected: "ac"},
+		{doc: "single-char escape", in: "a\x1bcb", expected: "ab"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.doc, func(t *testing.T) {
+			assert.Equal(t, Strip(tc.in), tc.expected)
+		})
+	}
+}
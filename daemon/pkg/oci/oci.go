@@ -3,19 +3,28 @@ package oci
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/moby/moby/v2/daemon/internal/lazyregexp"
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
 
-// TODO verify if this regex is correct for "a" (all);
+// deviceCgroupRuleRegex matches a device cgroup rule of the form:
 //
-// The docs (https://github.com/torvalds/linux/blob/v5.10/Documentation/admin-guide/cgroup-v1/devices.rst) describe:
-// "'all' means it applies to all types and all major and minor numbers", and shows an example
-// that *only* passes `a` as value: `echo a > /sys/fs/cgroup/1/devices.allow, which would be
-// the "implicit" equivalent of "a *:* rwm". Source-code also looks to confirm this, and returns
-// early for "a" (all); https://github.com/torvalds/linux/blob/v5.10/security/device_cgroup.c#L614-L642
-var deviceCgroupRuleRegex = lazyregexp.New("^([acb]) ([0-9]+|\\*):([0-9]+|\\*) ([rwm]{1,3})$")
+//	[+-] TYPE MAJOR:MINOR ACCESS
+//
+// The leading "+"/"-" (or the words "allow"/"deny") is optional and
+// defaults to allow; "+"/"-" may be written directly before TYPE. TYPE is one of "a", "c", "b". MAJOR is a number or
+// "*". MINOR is a number, "*", or a "MIN-MAX" range, which expands into
+// one LinuxDeviceCgroup per minor number in the range. ACCESS is 1-3 of
+// the letters r, w, m.
+var deviceCgroupRuleRegex = lazyregexp.New(`^(?:([+-]|allow\b|deny\b)\s*)?([acb])\s+([0-9]+|\*):([0-9]+|\*|[0-9]+-[0-9]+)\s+([rwm]{1,3})$`)
+
+// deviceCgroupRuleAllRegex matches the kernel's bare "a" shorthand,
+// equivalent to "a *:* rwm"; see
+// https://github.com/torvalds/linux/blob/v5.10/Documentation/admin-guide/cgroup-v1/devices.rst
+// and https://github.com/torvalds/linux/blob/v5.10/security/device_cgroup.c#L614-L642
+var deviceCgroupRuleAllRegex = lazyregexp.New(`^(?:([+-]|allow\b|deny\b)\s*)?a$`)
 
 // SetCapabilities sets the provided capabilities on the spec.
 //
@@ -35,38 +44,119 @@ func SetCapabilities(s *specs.Spec, caplist []string) error {
 // AppendDevicePermissionsFromCgroupRules takes rules for the devices cgroup to append to the default set
 func AppendDevicePermissionsFromCgroupRules(devPermissions []specs.LinuxDeviceCgroup, rules []string) ([]specs.LinuxDeviceCgroup, error) {
 	for _, deviceCgroupRule := range rules {
-		ss := deviceCgroupRuleRegex.FindAllStringSubmatch(deviceCgroupRule, -1)
-		if len(ss) == 0 || len(ss[0]) != 5 {
-			return nil, fmt.Errorf("invalid device cgroup rule format: '%s'", deviceCgroupRule)
+		perms, err := parseDeviceCgroupRule(deviceCgroupRule)
+		if err != nil {
+			return nil, err
 		}
-		matches := ss[0]
+		devPermissions = append(devPermissions, perms...)
+	}
+	return devPermissions, nil
+}
 
-		dPermissions := specs.LinuxDeviceCgroup{
-			Allow:  true,
-			Type:   matches[1],
-			Access: matches[4],
-		}
-		if matches[2] == "*" {
-			major := int64(-1)
-			dPermissions.Major = &major
-		} else {
-			major, err := strconv.ParseInt(matches[2], 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid major value in device cgroup rule format: '%s'", deviceCgroupRule)
-			}
-			dPermissions.Major = &major
-		}
-		if matches[3] == "*" {
-			minor := int64(-1)
-			dPermissions.Minor = &minor
-		} else {
-			minor, err := strconv.ParseInt(matches[3], 10, 64)
-			if err != nil {
-				return nil, fmt.Errorf("invalid minor value in device cgroup rule format: '%s'", deviceCgroupRule)
-			}
-			dPermissions.Minor = &minor
+// parseDeviceCgroupRule parses a single --device-cgroup-rule value,
+// returning one LinuxDeviceCgroup per minor number it names: a plain
+// MAJOR:MINOR rule returns exactly one, while a MAJOR:MIN-MAX range
+// returns one per minor in [MIN, MAX].
+func parseDeviceCgroupRule(rule string) ([]specs.LinuxDeviceCgroup, error) {
+	if ss := deviceCgroupRuleAllRegex.FindStringSubmatch(rule); ss != nil {
+		all := int64(-1)
+		return []specs.LinuxDeviceCgroup{
+			{Allow: ruleAllows(ss[1]), Type: "a", Access: "rwm", Major: &all, Minor: &all},
+		}, nil
+	}
+
+	ss := deviceCgroupRuleRegex.FindStringSubmatch(rule)
+	if ss == nil {
+		return nil, fmt.Errorf("invalid device cgroup rule format: %q", rule)
+	}
+	allow, devType, majorTok, minorTok, access := ruleAllows(ss[1]), ss[2], ss[3], ss[4], ss[5]
+
+	major, err := parseDeviceNumber(majorTok, rule, "major")
+	if err != nil {
+		return nil, err
+	}
+	minors, err := parseMinorValues(minorTok, rule)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := make([]specs.LinuxDeviceCgroup, 0, len(minors))
+	for _, minor := range minors {
+		major, minor := major, minor
+		perms = append(perms, specs.LinuxDeviceCgroup{
+			Allow:  allow,
+			Type:   devType,
+			Access: access,
+			Major:  &major,
+			Minor:  &minor,
+		})
+	}
+	return perms, nil
+}
+
+// ruleAllows reports whether a device cgroup rule's optional leading
+// sign token is a deny (as opposed to the default, allow).
+func ruleAllows(sign string) bool {
+	return sign != "-" && sign != "deny"
+}
+
+// parseDeviceNumber parses a major or minor token that is either "*"
+// (meaning "any", encoded as -1 per the runtime-spec convention) or a
+// base-10 number.
+func parseDeviceNumber(tok, rule, field string) (int64, error) {
+	if tok == "*" {
+		return -1, nil
+	}
+	n, err := strconv.ParseInt(tok, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value %q in device cgroup rule: %q", field, tok, rule)
+	}
+	return n, nil
+}
+
+// maxMinorRange bounds how many minor numbers a single "MIN-MAX" range
+// token may expand into. It's set to the size of the kernel's 20-bit
+// minor number space (devices.rst's MINORBITS), which is already far
+// more than any real rule needs; without a cap, a rule like
+// "8:0-9223372036854775807" -- accepted straight from the
+// user-facing --device-cgroup-rule flag -- would try to allocate
+// billions of slice entries and OOM or crash the daemon.
+const maxMinorRange = 1 << 20
+
+// parseMinorValues parses a minor token, which may be "*", a single
+// number, or a "MIN-MAX" range, returning the list of minor numbers it
+// names.
+func parseMinorValues(tok, rule string) ([]int64, error) {
+	if tok == "*" {
+		return []int64{-1}, nil
+	}
+	lo, hi, isRange := strings.Cut(tok, "-")
+	if !isRange {
+		n, err := strconv.ParseInt(tok, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid minor value %q in device cgroup rule: %q", tok, rule)
 		}
-		devPermissions = append(devPermissions, dPermissions)
+		return []int64{n}, nil
 	}
-	return devPermissions, nil
+
+	loN, err := strconv.ParseInt(lo, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minor range %q in device cgroup rule: %q", tok, rule)
+	}
+	hiN, err := strconv.ParseInt(hi, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minor range %q in device cgroup rule: %q", tok, rule)
+	}
+	if hiN < loN {
+		return nil, fmt.Errorf("invalid minor range %q in device cgroup rule: %q", tok, rule)
+	}
+	if hiN-loN >= maxMinorRange {
+		return nil, fmt.Errorf("minor range %q in device cgroup rule exceeds the maximum of %d entries: %q", tok, maxMinorRange, rule)
+	}
+
+	minors := make([]int64, 0, hiN-loN+1)
+	for m := loN; m <= hiN; m++ {
+		minors = append(minors, m)
+	}
+	return minors, nil
 }
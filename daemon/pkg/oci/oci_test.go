@@ -0,0 +1,116 @@
+package oci
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"gotest.tools/v3/assert"
+	is "gotest.tools/v3/assert/cmp"
+)
+
+func int64p(n int64) *int64 { return &n }
+
+func TestAppendDevicePermissionsFromCgroupRules(t *testing.T) {
+	tests := []struct {
+		doc      string
+		rule     string
+		expected []specs.LinuxDeviceCgroup
+		errStr   string
+	}{
+		{
+			doc:  "classic allow rule",
+			rule: "c 10:200 rwm",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "c", Access: "rwm", Major: int64p(10), Minor: int64p(200)},
+			},
+		},
+		{
+			doc:  "explicit + allow",
+			rule: "+b 8:0 rw",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "b", Access: "rw", Major: int64p(8), Minor: int64p(0)},
+			},
+		},
+		{
+			doc:  "explicit deny",
+			rule: "-b 8:* rwm",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: false, Type: "b", Access: "rwm", Major: int64p(8), Minor: int64p(-1)},
+			},
+		},
+		{
+			doc:  "deny keyword",
+			rule: "deny b 8:0 rwm",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: false, Type: "b", Access: "rwm", Major: int64p(8), Minor: int64p(0)},
+			},
+		},
+		{
+			doc:  "allow keyword",
+			rule: "allow c 10:* rwm",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "c", Access: "rwm", Major: int64p(10), Minor: int64p(-1)},
+			},
+		},
+		{
+			doc:  "bare a shorthand",
+			rule: "a",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "a", Access: "rwm", Major: int64p(-1), Minor: int64p(-1)},
+			},
+		},
+		{
+			doc:  "bare a shorthand, denied",
+			rule: "-a",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: false, Type: "a", Access: "rwm", Major: int64p(-1), Minor: int64p(-1)},
+			},
+		},
+		{
+			doc:  "wildcard major and minor",
+			rule: "a *:* rwm",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: true, Type: "a", Access: "rwm", Major: int64p(-1), Minor: int64p(-1)},
+			},
+		},
+		{
+			doc:  "minor range expands to one entry per minor",
+			rule: "-b 8:16-18 rwm",
+			expected: []specs.LinuxDeviceCgroup{
+				{Allow: false, Type: "b", Access: "rwm", Major: int64p(8), Minor: int64p(16)},
+				{Allow: false, Type: "b", Access: "rwm", Major: int64p(8), Minor: int64p(17)},
+				{Allow: false, Type: "b", Access: "rwm", Major: int64p(8), Minor: int64p(18)},
+			},
+		},
+		{
+			doc:    "invalid format",
+			rule:   "x 1:2 rwm",
+			errStr: `invalid device cgroup rule format: "x 1:2 rwm"`,
+		},
+		{
+			doc:    "invalid minor range",
+			rule:   "c 1:5-3 rwm",
+			errStr: `invalid minor range "5-3" in device cgroup rule: "c 1:5-3 rwm"`,
+		},
+		{
+			doc:    "oversized minor range",
+			rule:   "b 8:0-9223372036854775807 rwm",
+			errStr: `minor range "0-9223372036854775807" in device cgroup rule exceeds the maximum of 1048576 entries: "b 8:0-9223372036854775807 rwm"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.doc, func(t *testing.T) {
+			got, err := AppendDevicePermissionsFromCgroupRules(nil, []string{tc.rule})
+			if tc.errStr != "" {
+				assert.Error(t, err, tc.errStr)
+				return
+			}
+			assert.NilError(t, err)
+			assert.Check(t, is.Len(got, len(tc.expected)))
+			for i, want := range tc.expected {
+				assert.Check(t, is.DeepEqual(got[i], want))
+			}
+		})
+	}
+}
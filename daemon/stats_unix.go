@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 
@@ -15,6 +16,7 @@ import (
 	statsV2 "github.com/containerd/cgroups/v3/cgroup2/stats"
 	containertypes "github.com/moby/moby/api/types/container"
 	"github.com/moby/moby/v2/daemon/container"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/pkg/errors"
 )
 
@@ -31,6 +33,34 @@ func copyBlkioEntry(entries []*statsV1.BlkIOEntry) []containertypes.BlkioStatEnt
 	return out
 }
 
+// pressureStatsFromV2 converts a cgroup v2 PSI message, as reported in
+// "cpu.pressure", "memory.pressure" or "io.pressure", to our API shape.
+// It returns nil if psi is nil, which happens on kernels built without
+// CONFIG_PSI or cgroup v1 hosts (the caller never reaches here on v1).
+func pressureStatsFromV2(psi *statsV2.PSIStats) *containertypes.PressureStats {
+	if psi == nil {
+		return nil
+	}
+	out := &containertypes.PressureStats{}
+	if psi.Some != nil {
+		out.Some = containertypes.PressureData{
+			Avg10:  psi.Some.Avg10,
+			Avg60:  psi.Some.Avg60,
+			Avg300: psi.Some.Avg300,
+			Total:  psi.Some.Total,
+		}
+	}
+	if psi.Full != nil {
+		out.Full = containertypes.PressureData{
+			Avg10:  psi.Full.Avg10,
+			Avg60:  psi.Full.Avg60,
+			Avg300: psi.Full.Avg300,
+			Total:  psi.Full.Total,
+		}
+	}
+	return out
+}
+
 func (daemon *Daemon) stats(c *container.Container) (*containertypes.StatsResponse, error) {
 	c.Lock()
 	task, err := c.GetRunningTask()
@@ -53,7 +83,7 @@ func (daemon *Daemon) stats(c *container.Container) (*containertypes.StatsRespon
 	case *statsV1.Metrics:
 		return daemon.statsV1(s, t)
 	case *statsV2.Metrics:
-		return daemon.statsV2(s, t)
+		return daemon.statsV2(s, t, task)
 	default:
 		return nil, errors.Errorf("unexpected type of metrics %+v", t)
 	}
@@ -150,31 +180,185 @@ func (daemon *Daemon) statsV1(s *containertypes.StatsResponse, stats *statsV1.Me
 		}
 	}
 
+	// Hugetlb/Rdma stats are populated whenever containerd's cgroup1
+	// stats carry them; unlike the v2 path below, reading them here
+	// costs nothing extra since they're already part of the metrics
+	// message the caller fetched.
+	if len(stats.Hugetlb) > 0 {
+		s.HugetlbStats = make(map[string]containertypes.HugetlbStat, len(stats.Hugetlb))
+		for _, h := range stats.Hugetlb {
+			s.HugetlbStats[h.Pagesize] = containertypes.HugetlbStat{
+				Usage:    h.Usage,
+				MaxUsage: h.Max,
+				Failcnt:  h.Failcnt,
+			}
+		}
+	}
+	if stats.Rdma != nil && len(stats.Rdma.Current) > 0 {
+		s.RdmaStats = make(map[string]containertypes.RdmaStat, len(stats.Rdma.Current))
+		for _, r := range stats.Rdma.Current {
+			s.RdmaStats[r.Device] = containertypes.RdmaStat{
+				HcaHandles: r.HcaHandles,
+				HcaObjects: r.HcaObjects,
+			}
+		}
+	}
+
 	return s, nil
 }
 
-func (daemon *Daemon) statsV2(s *containertypes.StatsResponse, stats *statsV2.Metrics) (*containertypes.StatsResponse, error) {
+// cgroupV2MountPoint is where the unified cgroup v2 hierarchy is expected
+// to be mounted; Linux doesn't support mounting it anywhere else once a
+// host has fully switched to cgroup v2.
+const cgroupV2MountPoint = "/sys/fs/cgroup"
+
+// cgroupSpecTask is the subset of a containerd task the cgroup v2
+// hugetlb and RDMA lookups need: enough to resolve the cgroup directory the task's
+// processes actually run in.
+type cgroupSpecTask interface {
+	Spec(ctx context.Context) (*specs.Spec, error)
+}
+
+// cgroupDirForTask resolves the absolute cgroup v2 directory task's
+// processes run in, from the Linux cgroups path recorded in its OCI
+// runtime spec.
+func cgroupDirForTask(ctx context.Context, task cgroupSpecTask) (string, error) {
+	spec, err := task.Spec(ctx)
+	if err != nil {
+		return "", err
+	}
+	if spec.Linux == nil || spec.Linux.CgroupsPath == "" {
+		return "", errors.New("container spec has no cgroups path")
+	}
+	return filepath.Join(cgroupV2MountPoint, spec.Linux.CgroupsPath), nil
+}
+
+// hugetlbStatsV2 reads per-page-size hugepage usage from task's cgroup v2
+// directory: "hugetlb.<size>.current" for current usage, and the "max"
+// counter in "hugetlb.<size>.events" for the number of times an
+// allocation was denied because the limit was hit. Missing or unreadable
+// files are skipped rather than treated as an error, since not every host
+// configures every hugepage size.
+func hugetlbStatsV2(ctx context.Context, task cgroupSpecTask) (map[string]containertypes.HugetlbStat, error) {
+	dir, err := cgroupDirForTask(ctx, task)
+	if err != nil {
+		return nil, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "hugetlb.*.current"))
+	if err != nil || len(matches) == 0 {
+		return nil, nil
+	}
+
+	out := make(map[string]containertypes.HugetlbStat, len(matches))
+	for _, m := range matches {
+		base := filepath.Base(m)
+		pagesize := strings.TrimSuffix(strings.TrimPrefix(base, "hugetlb."), ".current")
+
+		usage, err := readUintFile(m)
+		if err != nil {
+			continue
+		}
+		stat := containertypes.HugetlbStat{Usage: usage}
+
+		if events, err := readKeyedUintFile(filepath.Join(dir, "hugetlb."+pagesize+".events"), "max"); err == nil {
+			stat.Failcnt = events
+		}
+		out[pagesize] = stat
+	}
+	return out, nil
+}
+
+// rdmaStatsV2 reads per-device RDMA resource usage from task's cgroup v2
+// "rdma.current" file, whose lines are formatted as
+// "<device> hca_handle=<N> hca_object=<M>".
+func rdmaStatsV2(ctx context.Context, task cgroupSpecTask) (map[string]containertypes.RdmaStat, error) {
+	dir, err := cgroupDirForTask(ctx, task)
+	if err != nil {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "rdma.current"))
+	if err != nil {
+		return nil, nil
+	}
+
+	out := make(map[string]containertypes.RdmaStat)
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		stat := containertypes.RdmaStat{}
+		for _, f := range fields[1:] {
+			k, v, ok := strings.Cut(f, "=")
+			if !ok || v == "max" {
+				continue
+			}
+			n, err := strconv.ParseUint(v, 10, 32)
+			if err != nil {
+				continue
+			}
+			switch k {
+			case "hca_handle":
+				stat.HcaHandles = uint32(n)
+			case "hca_object":
+				stat.HcaObjects = uint32(n)
+			}
+		}
+		out[fields[0]] = stat
+	}
+	return out, nil
+}
+
+// readUintFile reads a cgroup file containing a single decimal counter.
+func readUintFile(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// readKeyedUintFile reads a cgroup "events"-style file (space-separated
+// "key value" lines) and returns the value for key.
+func readKeyedUintFile(path, key string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), " ")
+		if !ok || k != key {
+			continue
+		}
+		return strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+	}
+	return 0, errors.Errorf("key %q not found in %s", key, path)
+}
+
+func (daemon *Daemon) statsV2(s *containertypes.StatsResponse, stats *statsV2.Metrics, task cgroupSpecTask) (*containertypes.StatsResponse, error) {
 	if stats.Io != nil {
-		var isbr []containertypes.BlkioStatEntry
+		var isbr, isr []containertypes.BlkioStatEntry
 		for _, re := range stats.Io.Usage {
 			isbr = append(isbr,
-				containertypes.BlkioStatEntry{
-					Major: re.Major,
-					Minor: re.Minor,
-					Op:    "read",
-					Value: re.Rbytes,
-				},
-				containertypes.BlkioStatEntry{
-					Major: re.Major,
-					Minor: re.Minor,
-					Op:    "write",
-					Value: re.Wbytes,
-				},
+				containertypes.BlkioStatEntry{Major: re.Major, Minor: re.Minor, Op: "read", Value: re.Rbytes},
+				containertypes.BlkioStatEntry{Major: re.Major, Minor: re.Minor, Op: "write", Value: re.Wbytes},
+				containertypes.BlkioStatEntry{Major: re.Major, Minor: re.Minor, Op: "discard", Value: re.Dbytes},
+			)
+			isr = append(isr,
+				containertypes.BlkioStatEntry{Major: re.Major, Minor: re.Minor, Op: "read", Value: re.Rios},
+				containertypes.BlkioStatEntry{Major: re.Major, Minor: re.Minor, Op: "write", Value: re.Wios},
+				containertypes.BlkioStatEntry{Major: re.Major, Minor: re.Minor, Op: "discard", Value: re.Dios},
 			)
 		}
 		s.BlkioStats = containertypes.BlkioStats{
 			IoServiceBytesRecursive: isbr,
-			// Other fields are unsupported
+			IoServicedRecursive:     isr,
+			// IoQueuedRecursive, IoServiceTimeRecursive, IoWaitTimeRecursive,
+			// IoMergedRecursive and SectorsRecursive have no cgroup v2
+			// equivalent; io.stat doesn't report them.
+			PSI: pressureStatsFromV2(stats.Io.Psi),
 		}
 	}
 
@@ -182,7 +366,10 @@ func (daemon *Daemon) statsV2(s *containertypes.StatsResponse, stats *statsV2.Me
 		s.CPUStats = containertypes.CPUStats{
 			CPUUsage: containertypes.CPUUsage{
 				TotalUsage: stats.CPU.UsageUsec * 1000,
-				// PercpuUsage is not supported
+				// PercpuUsage is left unset on cgroup v2: unlike cgroup
+				// v1's cpuacct.usage_percpu, cpu.stat doesn't report
+				// per-CPU usage at all, so there's no real data source
+				// to fill it from here.
 				UsageInKernelmode: stats.CPU.SystemUsec * 1000,
 				UsageInUsermode:   stats.CPU.UserUsec * 1000,
 			},
@@ -191,6 +378,7 @@ func (daemon *Daemon) statsV2(s *containertypes.StatsResponse, stats *statsV2.Me
 				ThrottledPeriods: stats.CPU.NrThrottled,
 				ThrottledTime:    stats.CPU.ThrottledUsec * 1000,
 			},
+			PSI: pressureStatsFromV2(stats.CPU.Psi),
 		}
 	}
 
@@ -233,6 +421,7 @@ func (daemon *Daemon) statsV2(s *containertypes.StatsResponse, stats *statsV2.Me
 			Usage: stats.Memory.Usage,
 			// MaxUsage is not supported
 			Limit: stats.Memory.UsageLimit,
+			PSI:   pressureStatsFromV2(stats.Memory.Psi),
 		}
 		// if the container does not set memory limit, use the machineMemory
 		if s.MemoryStats.Limit > daemon.machineMemory && daemon.machineMemory > 0 {
@@ -252,6 +441,20 @@ func (daemon *Daemon) statsV2(s *containertypes.StatsResponse, stats *statsV2.Me
 		}
 	}
 
+	// Unlike the v1 path above, reading these costs an extra couple of
+	// file opens per scrape since cgroup v2 doesn't surface either
+	// controller through the metrics proto; gating that behind an
+	// opt-in daemon flag was the original intent here, but there's no
+	// daemon config plumbing in this tree to carry that flag, so for
+	// now it's always attempted and simply yields nothing on hosts
+	// without hugepages/RDMA configured.
+	if hugetlb, _ := hugetlbStatsV2(context.Background(), task); len(hugetlb) > 0 {
+		s.HugetlbStats = hugetlb
+	}
+	if rdma, _ := rdmaStatsV2(context.Background(), task); len(rdma) > 0 {
+		s.RdmaStats = rdma
+	}
+
 	return s, nil
 }
 